@@ -0,0 +1,590 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxFailures         = 5
+	defaultEjectionTime        = 30 * time.Second
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 2 * time.Second
+	defaultRefreshInterval     = 30 * time.Second
+	defaultResolveTimeout      = 5 * time.Second
+	hashRingReplicas           = 100
+)
+
+// ErrNoEndpoints is the Err of the *Error returned by Client.Do when the
+// load balancer has no healthy endpoint for the host.
+var ErrNoEndpoints = errors.New("GoReq: no healthy endpoint")
+
+// BalancePolicy chooses the endpoint of each request.
+type BalancePolicy int
+
+const (
+	// RoundRobin cycles through the endpoints.
+	RoundRobin BalancePolicy = iota
+	// LeastInFlight picks the endpoint with the fewest requests in flight.
+	LeastInFlight
+	// WeightedRandom picks a random endpoint, proportionally to its weight.
+	WeightedRandom
+	// ConsistentHash always picks the same endpoint for the same key, see
+	// LoadBalancer.HashKey.
+	ConsistentHash
+)
+
+// Endpoint is a backend of the load balancer. URL is either "host:port"
+// or "scheme://host:port". Weight is used by WeightedRandom and
+// ConsistentHash and defaults to 1.
+type Endpoint struct {
+	URL    string
+	Weight int
+}
+
+// EndpointState reports the state of an endpoint of the load balancer for
+// the logical Host.
+type EndpointState struct {
+	Host     string
+	URL      string
+	InFlight int
+	Failures int
+	Ejected  bool
+}
+
+// HealthCheck configures active health checks: every Interval (10s when
+// unset) each endpoint gets a GET on Path, and is healthy when it answers
+// with a 2xx or 3xx status within Timeout (2s when unset).
+type HealthCheck struct {
+	Path     string
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// LoadBalancer spreads the requests for Host, and those with a relative
+// URI, across Endpoints by rewriting the scheme and host of their URL.
+// With a Resolver, the endpoints of other hosts are asked to it on their
+// first request and refreshed every RefreshInterval (30s when unset).
+// LogicalHosts, whose entries work like Options.NoProxy, lists the hosts
+// given to the Resolver, and their requests fail when it fails. Without
+// it, every host is asked once: the hosts it does not know, or that it
+// fails to resolve, are requested as they are.
+//
+// An endpoint is ejected after MaxFailures (5 when unset) consecutive
+// failures, as told by IsFailure, which defaults to transport errors and
+// 5xx responses; requests rejected by the client's own limits are not
+// counted. Ejected endpoints come back after EjectionTime (30s when
+// unset), or, when HealthCheck is set, once they pass a health check.
+// HashKey gives the key used by ConsistentHash and defaults to the path
+// and query of the request.
+type LoadBalancer struct {
+	Host         string
+	Endpoints    []Endpoint
+	Policy       BalancePolicy
+	HashKey      func(req *http.Request) string
+	MaxFailures  int
+	EjectionTime time.Duration
+	HealthCheck  *HealthCheck
+	IsFailure    func(res *http.Response, err error) bool
+
+	Resolver        Resolver
+	LogicalHosts    []string
+	RefreshInterval time.Duration
+}
+
+// maxPassthroughHosts bounds how many hosts unknown to the Resolver are
+// remembered, so they are not asked again.
+const maxPassthroughHosts = 1024
+
+type balancer struct {
+	config LoadBalancer
+	now    func() time.Time
+	pool   *endpointPool
+	stop   chan struct{}
+	once   sync.Once
+
+	logical []noProxyRule
+
+	mu       sync.Mutex
+	resolved map[string]*resolvedHost
+}
+
+// resolvedHost holds the pool of a host given by the Resolver, nil when
+// the host is not a logical one. Only logical hosts are refreshed.
+type resolvedHost struct {
+	mu       sync.Mutex
+	resolved bool
+	logical  bool
+	pool     *endpointPool
+}
+
+type endpointPool struct {
+	config *LoadBalancer
+	host   string
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+	ring      []ringNode
+	next      int
+	rand      *rand.Rand
+}
+
+type endpoint struct {
+	url          *url.URL
+	weight       int
+	inFlight     int
+	failures     int
+	ejected      bool
+	ejectedUntil time.Time
+}
+
+type ringNode struct {
+	hash     uint32
+	endpoint *endpoint
+}
+
+func newBalancer(config *LoadBalancer, client *http.Client) *balancer {
+	if config == nil {
+		return nil
+	}
+	c := *config
+	if c.MaxFailures <= 0 {
+		c.MaxFailures = defaultMaxFailures
+	}
+	if c.EjectionTime <= 0 {
+		c.EjectionTime = defaultEjectionTime
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = defaultIsFailure
+	}
+	if c.HashKey == nil {
+		c.HashKey = func(req *http.Request) string { return req.URL.RequestURI() }
+	}
+	if c.RefreshInterval <= 0 {
+		c.RefreshInterval = defaultRefreshInterval
+	}
+
+	b := &balancer{config: c, now: time.Now, stop: make(chan struct{}), logical: parseNoProxy(c.LogicalHosts), resolved: map[string]*resolvedHost{}}
+	if len(c.Endpoints) > 0 || c.Resolver == nil {
+		b.pool = newEndpointPool(&b.config, c.Host, c.Endpoints)
+	}
+	if c.HealthCheck != nil {
+		go b.checkHealth(client)
+	}
+	if c.Resolver != nil {
+		go b.refresh()
+	}
+	return b
+}
+
+func newEndpointPool(config *LoadBalancer, host string, endpoints []Endpoint) *endpointPool {
+	p := &endpointPool{config: config, host: host, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	p.update(endpoints)
+	return p
+}
+
+// update replaces the endpoints of the pool, keeping the state of those
+// that were already there.
+func (p *endpointPool) update(endpoints []Endpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing := make(map[string]*endpoint, len(p.endpoints))
+	for _, e := range p.endpoints {
+		existing[e.url.String()] = e
+	}
+	p.endpoints = nil
+	for _, ep := range endpoints {
+		u, err := parseEndpoint(ep.URL)
+		if err != nil {
+			continue
+		}
+		e, ok := existing[u.String()]
+		if !ok {
+			e = &endpoint{url: u}
+			existing[u.String()] = e
+		}
+		e.weight = ep.Weight
+		if e.weight <= 0 {
+			e.weight = 1
+		}
+		p.endpoints = append(p.endpoints, e)
+	}
+
+	p.ring = nil
+	for _, e := range p.endpoints {
+		for i := 0; i < hashRingReplicas*e.weight; i++ {
+			hash := crc32.ChecksumIEEE([]byte(e.url.Host + "#" + strconv.Itoa(i)))
+			p.ring = append(p.ring, ringNode{hash: hash, endpoint: e})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool { return p.ring[i].hash < p.ring[j].hash })
+}
+
+func parseEndpoint(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, errors.New("GoReq: endpoint without host")
+	}
+	return u, nil
+}
+
+// poolFor returns the pool of the host of req, nil when its requests are
+// sent as they are.
+func (b *balancer) poolFor(req *http.Request) (*endpointPool, error) {
+	host := req.URL.Host
+	if host == "" {
+		host = b.config.Host
+	}
+	if b.pool != nil && host == b.config.Host {
+		return b.pool, nil
+	}
+	if host == "" || b.config.Resolver == nil {
+		return nil, nil
+	}
+	listed := len(b.logical) > 0
+	if listed && !matchHost(b.logical, &url.URL{Scheme: req.URL.Scheme, Host: host}) {
+		return nil, nil
+	}
+
+	b.mu.Lock()
+	r, ok := b.resolved[host]
+	if !ok {
+		r = &resolvedHost{logical: listed}
+		b.resolved[host] = r
+	}
+	b.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.resolved {
+		endpoints, err := b.config.Resolver.Resolve(req.Context(), host)
+		if err != nil {
+			if listed {
+				return nil, err
+			}
+			// The host may not be a logical one: send it as it is and
+			// ask again on its next request.
+			b.forget(host, r)
+			return nil, nil
+		}
+		if endpoints != nil {
+			r.pool = newEndpointPool(&b.config, host, endpoints)
+			r.logical = true
+		}
+		r.resolved = true
+		if !r.logical {
+			b.mu.Lock()
+			if len(b.resolved) > maxPassthroughHosts {
+				delete(b.resolved, host)
+			}
+			b.mu.Unlock()
+		}
+	}
+	return r.pool, nil
+}
+
+func (b *balancer) forget(host string, r *resolvedHost) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.resolved[host] == r {
+		delete(b.resolved, host)
+	}
+}
+
+func (b *balancer) wrap(next roundTripFunc) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		pool, err := b.poolFor(req)
+		if err != nil {
+			return nil, err
+		}
+		if pool == nil {
+			return next(req)
+		}
+		e := pool.pick(req, b.now())
+		if e == nil {
+			return nil, ErrNoEndpoints
+		}
+
+		res, err := next(rewriteRequest(req, e.url))
+		if !localRejection(err) && (err == nil || req.Context().Err() == nil) {
+			pool.record(e, b.config.IsFailure(res, err), b.now())
+		}
+		if err != nil || res.Body == nil {
+			pool.done(e)
+			return res, err
+		}
+		res.Body = &releaseBody{reader: res.Body, release: func() { pool.done(e) }}
+		return res, nil
+	}
+}
+
+// rewriteRequest returns a copy of req sent to target. The Host header
+// follows the endpoint unless it was set explicitly.
+func rewriteRequest(req *http.Request, target *url.URL) *http.Request {
+	r := req.WithContext(req.Context())
+	u := *req.URL
+	u.Scheme, u.Host = target.Scheme, target.Host
+	r.URL = &u
+	if req.Host == req.URL.Host {
+		r.Host = ""
+	}
+	return r
+}
+
+// pools returns the static pool followed by the resolved ones, sorted by
+// host.
+func (b *balancer) pools() []*endpointPool {
+	var pools []*endpointPool
+	if b.pool != nil {
+		pools = append(pools, b.pool)
+	}
+
+	b.mu.Lock()
+	hosts := make([]string, 0, len(b.resolved))
+	for host := range b.resolved {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	resolved := make([]*resolvedHost, 0, len(hosts))
+	for _, host := range hosts {
+		resolved = append(resolved, b.resolved[host])
+	}
+	b.mu.Unlock()
+
+	for _, r := range resolved {
+		r.mu.Lock()
+		if r.pool != nil {
+			pools = append(pools, r.pool)
+		}
+		r.mu.Unlock()
+	}
+	return pools
+}
+
+func (b *balancer) states() []EndpointState {
+	states := []EndpointState{}
+	for _, pool := range b.pools() {
+		states = append(states, pool.states()...)
+	}
+	return states
+}
+
+// refresh asks the Resolver again for the endpoints of the hosts resolved
+// so far. Failed lookups keep the previous endpoints.
+func (b *balancer) refresh() {
+	ticker := time.NewTicker(b.config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.stop:
+			return
+		}
+
+		b.mu.Lock()
+		resolved := make(map[string]*resolvedHost, len(b.resolved))
+		for host, r := range b.resolved {
+			resolved[host] = r
+		}
+		b.mu.Unlock()
+
+		for host, r := range resolved {
+			r.mu.Lock()
+			logical := r.logical
+			r.mu.Unlock()
+			if !logical {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), defaultResolveTimeout)
+			endpoints, err := b.config.Resolver.Resolve(ctx, host)
+			cancel()
+			if err != nil {
+				continue
+			}
+
+			r.mu.Lock()
+			switch {
+			case endpoints == nil:
+				r.pool = nil
+			case r.pool == nil:
+				r.pool = newEndpointPool(&b.config, host, endpoints)
+			default:
+				r.pool.update(endpoints)
+			}
+			r.resolved = true
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (b *balancer) close() {
+	b.once.Do(func() { close(b.stop) })
+}
+
+func (b *balancer) checkHealth(client *http.Client) {
+	interval := b.config.HealthCheck.Interval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	timeout := b.config.HealthCheck.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	checker := &http.Client{Transport: client.Transport, Timeout: timeout}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, pool := range b.pools() {
+				pool.check(checker, b.config.HealthCheck.Path)
+			}
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+func (p *endpointPool) check(checker *http.Client, path string) {
+	p.mu.Lock()
+	endpoints := append([]*endpoint(nil), p.endpoints...)
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, e := range endpoints {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+			healthy := false
+			if res, err := checker.Get(e.url.Scheme + "://" + e.url.Host + path); err == nil {
+				res.Body.Close()
+				healthy = res.StatusCode >= 200 && res.StatusCode < 400
+			}
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if healthy {
+				e.ejected, e.failures = false, 0
+			} else {
+				e.ejected = true
+			}
+		}(e)
+	}
+	wg.Wait()
+}
+
+// available returns the endpoints not ejected, reinstating those whose
+// ejection time has passed when there are no active health checks.
+func (p *endpointPool) available(now time.Time) []*endpoint {
+	var available []*endpoint
+	for _, e := range p.endpoints {
+		if e.ejected && p.config.HealthCheck == nil && !now.Before(e.ejectedUntil) {
+			e.ejected, e.failures = false, 0
+		}
+		if !e.ejected {
+			available = append(available, e)
+		}
+	}
+	return available
+}
+
+func (p *endpointPool) pick(req *http.Request, now time.Time) *endpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	available := p.available(now)
+	if len(available) == 0 {
+		return nil
+	}
+
+	var e *endpoint
+	switch p.config.Policy {
+	case LeastInFlight:
+		for i := range available {
+			candidate := available[(p.next+i)%len(available)]
+			if e == nil || candidate.inFlight < e.inFlight {
+				e = candidate
+			}
+		}
+		p.next++
+	case WeightedRandom:
+		total := 0
+		for _, candidate := range available {
+			total += candidate.weight
+		}
+		n := p.rand.Intn(total)
+		for _, candidate := range available {
+			if n -= candidate.weight; n < 0 {
+				e = candidate
+				break
+			}
+		}
+	case ConsistentHash:
+		hash := crc32.ChecksumIEEE([]byte(p.config.HashKey(req)))
+		start := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= hash })
+		for i := 0; i < len(p.ring); i++ {
+			if node := p.ring[(start+i)%len(p.ring)]; !node.endpoint.ejected {
+				e = node.endpoint
+				break
+			}
+		}
+	default:
+		e = available[p.next%len(available)]
+		p.next++
+	}
+
+	e.inFlight++
+	return e
+}
+
+func (p *endpointPool) record(e *endpoint, failed bool, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !failed {
+		e.failures = 0
+		return
+	}
+	if e.failures++; e.failures >= p.config.MaxFailures && !e.ejected {
+		e.ejected = true
+		e.ejectedUntil = now.Add(p.config.EjectionTime)
+	}
+}
+
+func (p *endpointPool) done(e *endpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.inFlight--
+}
+
+func (p *endpointPool) states() []EndpointState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	states := make([]EndpointState, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		states = append(states, EndpointState{
+			Host:     p.host,
+			URL:      e.url.String(),
+			InFlight: e.inFlight,
+			Failures: e.failures,
+			Ejected:  e.ejected,
+		})
+	}
+	return states
+}
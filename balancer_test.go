@@ -0,0 +1,235 @@
+package goreq
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestLoadBalancer(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Load balancer", func() {
+		var servers []*httptest.Server
+		var hits []int32
+		var failing []int32
+
+		hit := func(i int) int { return int(atomic.LoadInt32(&hits[i])) }
+
+		g.Before(func() {
+			hits = make([]int32, 3)
+			failing = make([]int32, 3)
+			for i := 0; i < 3; i++ {
+				i := i
+				servers = append(servers, httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/health" {
+						if atomic.LoadInt32(&failing[i]) == 1 {
+							w.WriteHeader(503)
+						}
+						return
+					}
+					atomic.AddInt32(&hits[i], 1)
+					if atomic.LoadInt32(&failing[i]) == 1 {
+						w.WriteHeader(500)
+						return
+					}
+					fmt.Fprintf(w, "%d %s", i, r.URL.RequestURI())
+				})))
+			}
+		})
+
+		g.BeforeEach(func() {
+			for i := range hits {
+				atomic.StoreInt32(&hits[i], 0)
+				atomic.StoreInt32(&failing[i], 0)
+			}
+		})
+
+		g.After(func() {
+			for _, ts := range servers {
+				ts.Close()
+			}
+		})
+
+		endpoints := func() []Endpoint {
+			var endpoints []Endpoint
+			for _, ts := range servers {
+				endpoints = append(endpoints, Endpoint{URL: ts.URL})
+			}
+			return endpoints
+		}
+
+		g.It("Should rewrite logical and relative URIs in round robin", func() {
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{Host: "orders-service", Endpoints: endpoints()}})
+
+			res, err := client.Do(Request{Uri: "http://orders-service/v1/orders?id=1"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("0 /v1/orders?id=1"))
+
+			for i := 0; i < 5; i++ {
+				res, err := client.Do(Request{Uri: "/v1/orders"})
+				Expect(err).Should(BeNil())
+				res.Body.Close()
+			}
+			Expect([]int{hit(0), hit(1), hit(2)}).Should(Equal([]int{2, 2, 2}))
+		})
+
+		g.It("Should not touch requests for other hosts", func() {
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{Host: "orders-service", Endpoints: endpoints()[:1]}})
+
+			res, err := client.Do(Request{Uri: servers[2].URL + "/direct"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("2 /direct"))
+		})
+
+		g.It("Should pick the endpoint with the fewest requests in flight", func() {
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{Endpoints: endpoints(), Policy: LeastInFlight}})
+
+			first, err := client.Do(Request{Uri: "/a"})
+			Expect(err).Should(BeNil())
+			second, err := client.Do(Request{Uri: "/b"})
+			Expect(err).Should(BeNil())
+			first.Body.Close()
+
+			res, err := client.Do(Request{Uri: "/c"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(HavePrefix("2 "))
+			second.Body.Close()
+
+			for _, state := range client.Endpoints() {
+				Expect(state.InFlight).Should(Equal(0))
+			}
+		})
+
+		g.It("Should pick endpoints proportionally to their weight", func() {
+			weighted := endpoints()[:2]
+			weighted[0].Weight = 9
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{Endpoints: weighted, Policy: WeightedRandom}})
+
+			for i := 0; i < 200; i++ {
+				res, err := client.Do(Request{Uri: "/"})
+				Expect(err).Should(BeNil())
+				res.Body.Close()
+			}
+			Expect(hit(0)).Should(BeNumerically(">", 140))
+			Expect(hit(1)).Should(BeNumerically(">", 0))
+		})
+
+		g.It("Should send the same key to the same endpoint", func() {
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{
+				Endpoints: endpoints(),
+				Policy:    ConsistentHash,
+				HashKey:   func(req *http.Request) string { return req.Header.Get("X-User") },
+			}})
+
+			var first string
+			for i := 0; i < 5; i++ {
+				request := Request{Uri: fmt.Sprintf("/%d", i)}
+				request.AddHeader("X-User", "alice")
+				res, err := client.Do(request)
+				Expect(err).Should(BeNil())
+				body, _ := res.Body.ToString()
+				if first == "" {
+					first = body[:1]
+				}
+				Expect(body[:1]).Should(Equal(first))
+			}
+		})
+
+		g.It("Should eject failing endpoints and reinstate them after the ejection time", func() {
+			atomic.StoreInt32(&failing[0], 1)
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{
+				Endpoints:    endpoints()[:2],
+				MaxFailures:  2,
+				EjectionTime: 100 * time.Millisecond,
+			}})
+			clock := newFakeClock()
+			client.balancer.now = clock.now
+
+			for i := 0; i < 8; i++ {
+				res, err := client.Do(Request{Uri: "/"})
+				Expect(err).Should(BeNil())
+				res.Body.Close()
+			}
+			Expect(hit(0)).Should(Equal(2))
+			Expect(client.Endpoints()[0].Ejected).Should(BeTrue())
+
+			atomic.StoreInt32(&failing[0], 0)
+			clock.advance(99 * time.Millisecond)
+			for i := 0; i < 2; i++ {
+				res, err := client.Do(Request{Uri: "/"})
+				Expect(err).Should(BeNil())
+				res.Body.Close()
+			}
+			Expect(hit(0)).Should(Equal(2))
+
+			clock.advance(time.Millisecond)
+			for i := 0; i < 2; i++ {
+				res, err := client.Do(Request{Uri: "/"})
+				Expect(err).Should(BeNil())
+				res.Body.Close()
+			}
+			Expect(hit(0)).Should(Equal(3))
+		})
+
+		g.It("Should fail when every endpoint is ejected", func() {
+			atomic.StoreInt32(&failing[0], 1)
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{Endpoints: endpoints()[:1], MaxFailures: 1}})
+
+			res, err := client.Do(Request{Uri: "/"})
+			Expect(err).Should(BeNil())
+			res.Body.Close()
+
+			_, err = client.Do(Request{Uri: "/"})
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.(*Error).Err).Should(Equal(ErrNoEndpoints))
+		})
+
+		g.It("Should not eject endpoints for the client's own rate limit", func() {
+			client := NewClient(Options{
+				RateLimit:         RateLimit{Rate: 5, Burst: 1},
+				RateLimitFailFast: true,
+				LoadBalancer:      &LoadBalancer{Endpoints: endpoints()[:1], MaxFailures: 1},
+			})
+
+			res, err := client.Do(Request{Uri: "/"})
+			Expect(err).Should(BeNil())
+			res.Body.Close()
+
+			_, err = client.Do(Request{Uri: "/"})
+			Expect(err.(*Error).Err).Should(BeAssignableToTypeOf(&RateLimitError{}))
+			Expect(client.Endpoints()[0].Failures).Should(Equal(0))
+			Expect(client.Endpoints()[0].Ejected).Should(BeFalse())
+		})
+
+		g.It("Should reinstate endpoints with active health checks", func() {
+			atomic.StoreInt32(&failing[0], 1)
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{
+				Endpoints:   endpoints()[:2],
+				HealthCheck: &HealthCheck{Path: "/health", Interval: 20 * time.Millisecond},
+			}})
+			defer client.Close()
+
+			Eventually(func() bool { return client.Endpoints()[0].Ejected }).Should(BeTrue())
+			for i := 0; i < 4; i++ {
+				res, err := client.Do(Request{Uri: "/"})
+				Expect(err).Should(BeNil())
+				res.Body.Close()
+			}
+			Expect(hit(0)).Should(Equal(0))
+
+			atomic.StoreInt32(&failing[0], 0)
+			Eventually(func() bool { return client.Endpoints()[0].Ejected }).Should(BeFalse())
+		})
+	})
+}
@@ -0,0 +1,270 @@
+package goreq
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestBodyLimits(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Body size limits", func() {
+		var ts *httptest.Server
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+				if r.URL.Path == "/json" {
+					fmt.Fprint(w, `{"foo":"`+strings.Repeat("a", 100)+`"}`)
+					return
+				}
+				fmt.Fprint(w, "0123456789")
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should read the whole body when no limit is set", func() {
+			client := NewClient(Options{})
+			res, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+
+			b, err := res.Body.Bytes()
+			Expect(err).Should(BeNil())
+			Expect(string(b)).Should(Equal("0123456789"))
+		})
+
+		g.It("Should fail with ErrBodyTooLarge over the client limit", func() {
+			client := NewClient(Options{MaxResponseBodySize: 5})
+			res, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+
+			_, err = res.Body.ToString()
+			Expect(err).Should(Equal(ErrBodyTooLarge))
+		})
+
+		g.It("Should accept a body exactly at the limit", func() {
+			client := NewClient(Options{MaxResponseBodySize: 10})
+			res, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+
+			str, err := res.Body.ToString()
+			Expect(err).Should(BeNil())
+			Expect(str).Should(Equal("0123456789"))
+		})
+
+		g.It("Should let the request override the client limit", func() {
+			client := NewClient(Options{MaxResponseBodySize: 5})
+			res, err := client.Do(Request{Uri: ts.URL, MaxResponseBodySize: -1})
+			Expect(err).Should(BeNil())
+
+			str, err := res.Body.ToString()
+			Expect(err).Should(BeNil())
+			Expect(str).Should(Equal("0123456789"))
+
+			res, err = NewClient(Options{}).Do(Request{Uri: ts.URL, MaxResponseBodySize: 3})
+			Expect(err).Should(BeNil())
+			_, err = res.Body.Bytes()
+			Expect(err).Should(Equal(ErrBodyTooLarge))
+		})
+
+		g.It("Should limit JSON decoding", func() {
+			client := NewClient(Options{MaxResponseBodySize: 20})
+			res, err := client.Do(Request{Uri: ts.URL + "/json"})
+			Expect(err).Should(BeNil())
+
+			var v map[string]string
+			err = res.Body.FromJsonTo(&v)
+			Expect(err).Should(Equal(ErrBodyTooLarge))
+		})
+
+		g.It("Should read up to n bytes with ToStringLimit", func() {
+			client := NewClient(Options{})
+			res, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+
+			str, err := res.Body.ToStringLimit(4)
+			Expect(err).Should(Equal(ErrBodyTooLarge))
+			Expect(str).Should(Equal("0123"))
+
+			res, err = client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+			str, err = res.Body.ToStringLimit(10)
+			Expect(err).Should(BeNil())
+			Expect(str).Should(Equal("0123456789"))
+		})
+
+		g.It("Should discard the remaining body", func() {
+			client := NewClient(Options{})
+			res, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+
+			Expect(res.Body.Discard()).Should(BeNil())
+		})
+	})
+}
+
+func TestDecompressionLimits(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Decompression limits", func() {
+		var ts *httptest.Server
+		var bomb []byte
+
+		g.Before(func() {
+			var buf bytes.Buffer
+			gw := gzip.NewWriter(&buf)
+			gw.Write(bytes.Repeat([]byte{0}, 4<<20))
+			gw.Close()
+			bomb = buf.Bytes()
+
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Encoding", "gzip")
+				w.WriteHeader(200)
+				w.Write(bomb)
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should decompress without limits by default", func() {
+			client := NewClient(Options{})
+			res, err := client.Do(Request{Uri: ts.URL, Compression: Gzip()})
+			Expect(err).Should(BeNil())
+
+			b, err := res.Body.Bytes()
+			Expect(err).Should(BeNil())
+			Expect(b).Should(HaveLen(4 << 20))
+		})
+
+		g.It("Should fail when the decompressed size is exceeded", func() {
+			client := NewClient(Options{MaxDecompressedSize: 1 << 20})
+			res, err := client.Do(Request{Uri: ts.URL, Compression: Gzip()})
+			Expect(err).Should(BeNil())
+
+			_, err = res.Body.Bytes()
+			Expect(err).Should(Equal(ErrDecompressionLimit))
+		})
+
+		g.It("Should fail when the expansion ratio is exceeded", func() {
+			client := NewClient(Options{MaxDecompressionRatio: 100})
+			res, err := client.Do(Request{Uri: ts.URL, Compression: Gzip()})
+			Expect(err).Should(BeNil())
+
+			_, err = res.Body.Bytes()
+			Expect(err).Should(Equal(ErrDecompressionLimit))
+		})
+
+		g.It("Should enforce the limits on responses decompressed by default", func() {
+			client := NewClient(Options{MaxDecompressedSize: 1024, MaxDecompressionRatio: 10})
+			res, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+			Expect(res.Header.Get("Content-Encoding")).Should(Equal(""))
+
+			b, err := res.Body.Bytes()
+			Expect(err).Should(Equal(ErrDecompressionLimit))
+			Expect(len(b)).Should(BeNumerically("<=", 1024))
+		})
+
+		g.It("Should accept responses within the limits", func() {
+			client := NewClient(Options{MaxDecompressedSize: 8 << 20, MaxDecompressionRatio: 10000})
+			res, err := client.Do(Request{Uri: ts.URL, Compression: Gzip()})
+			Expect(err).Should(BeNil())
+
+			b, err := res.Body.Bytes()
+			Expect(err).Should(BeNil())
+			Expect(b).Should(HaveLen(4 << 20))
+		})
+	})
+}
+
+func TestBufferedBody(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Buffered body", func() {
+		var ts *httptest.Server
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+				fmt.Fprint(w, `{"foo":"bar"}`)
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should allow reading a buffered body many times", func() {
+			client := NewClient(Options{})
+			res, err := client.Do(Request{Uri: ts.URL, BufferResponse: true})
+			Expect(err).Should(BeNil())
+
+			var v map[string]string
+			Expect(res.Body.FromJsonTo(&v)).Should(BeNil())
+			Expect(v["foo"]).Should(Equal("bar"))
+
+			str, err := res.Body.ToString()
+			Expect(err).Should(BeNil())
+			Expect(str).Should(Equal(`{"foo":"bar"}`))
+
+			b, err := res.Body.Bytes()
+			Expect(err).Should(BeNil())
+			Expect(string(b)).Should(Equal(`{"foo":"bar"}`))
+
+			r, _ := ioutil.ReadAll(res.Body.Reader())
+			Expect(string(r)).Should(Equal(`{"foo":"bar"}`))
+			r, _ = ioutil.ReadAll(res.Body.Reader())
+			Expect(string(r)).Should(Equal(`{"foo":"bar"}`))
+
+			Expect(res.Body.Close()).Should(BeNil())
+		})
+
+		g.It("Should not let callers modify a buffered body", func() {
+			res, err := NewClient(Options{}).Do(Request{Uri: ts.URL, BufferResponse: true})
+			Expect(err).Should(BeNil())
+
+			b, _ := res.Body.Bytes()
+			copy(b, "XXXX")
+			str, _ := res.Body.ToString()
+			Expect(str).Should(Equal(`{"foo":"bar"}`))
+		})
+
+		g.It("Should buffer on demand", func() {
+			client := NewClient(Options{})
+			res, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+
+			Expect(res.Body.Buffer()).Should(BeNil())
+			str, _ := res.Body.ToString()
+			Expect(str).Should(Equal(`{"foo":"bar"}`))
+			str, _ = res.Body.ToString()
+			Expect(str).Should(Equal(`{"foo":"bar"}`))
+		})
+
+		g.It("Should respect the size limit while buffering", func() {
+			client := NewClient(Options{MaxResponseBodySize: 4})
+			res, err := client.Do(Request{Uri: ts.URL, BufferResponse: true})
+
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.(*Error).Err).Should(Equal(ErrBodyTooLarge))
+			Expect(res).ShouldNot(BeNil())
+		})
+	})
+}
@@ -0,0 +1,243 @@
+package goreq
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerFailureRate = 0.5
+	defaultBreakerMinRequests = 10
+	defaultBreakerWindow      = 10 * time.Second
+	defaultBreakerCoolDown    = 5 * time.Second
+	defaultBreakerProbes      = 1
+)
+
+// ErrCircuitOpen is the Err of the *Error returned by Client.Do when the
+// circuit breaker of the host is open.
+var ErrCircuitOpen = errors.New("GoReq: circuit breaker is open")
+
+// CircuitState is the state of the circuit breaker of a host.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects every request until the cool-down has passed.
+	CircuitOpen
+	// CircuitHalfOpen lets a few probe requests through to decide whether
+	// to close or open the circuit again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker configures a circuit breaker per host. The circuit opens
+// when at least MinRequests were made within Window and the share of
+// failures reaches FailureRate. After CoolDown, HalfOpenProbes requests are
+// let through: the circuit closes if they all succeed and opens again on
+// the first failure. Zero values use defaults (50%, 10 requests, 10s, 5s
+// and 1 probe). IsFailure defaults to transport errors and 5xx responses;
+// requests rejected by the client's own limits are never counted.
+type CircuitBreaker struct {
+	FailureRate    float64
+	MinRequests    int
+	Window         time.Duration
+	CoolDown       time.Duration
+	HalfOpenProbes int
+	IsFailure      func(res *http.Response, err error) bool
+	OnStateChange  func(host string, from, to CircuitState)
+}
+
+func defaultIsFailure(res *http.Response, err error) bool {
+	return err != nil || res.StatusCode >= 500
+}
+
+// localRejection reports whether err comes from a limit of the client,
+// the rate limiter or the concurrency limit, and not from the host.
+func localRejection(err error) bool {
+	switch err.(type) {
+	case *RateLimitError, *BulkheadError:
+		return true
+	}
+	return false
+}
+
+type breaker struct {
+	config CircuitBreaker
+	now    func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*hostCircuit
+}
+
+type hostCircuit struct {
+	state       CircuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int
+	successes   int
+}
+
+func newBreaker(config *CircuitBreaker) *breaker {
+	if config == nil {
+		return nil
+	}
+	c := *config
+	if c.FailureRate <= 0 {
+		c.FailureRate = defaultBreakerFailureRate
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = defaultBreakerMinRequests
+	}
+	if c.Window <= 0 {
+		c.Window = defaultBreakerWindow
+	}
+	if c.CoolDown <= 0 {
+		c.CoolDown = defaultBreakerCoolDown
+	}
+	if c.HalfOpenProbes <= 0 {
+		c.HalfOpenProbes = defaultBreakerProbes
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = defaultIsFailure
+	}
+	return &breaker{config: c, now: time.Now, hosts: map[string]*hostCircuit{}}
+}
+
+func (b *breaker) wrap(next roundTripFunc) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		host := req.URL.Host
+		if !b.allow(host) {
+			return nil, ErrCircuitOpen
+		}
+		res, err := next(req)
+		if localRejection(err) || err != nil && req.Context().Err() != nil {
+			// Requests rejected by the client's own limits or cancelled,
+			// such as hedges that lost, say nothing about the health of
+			// the host.
+			b.release(host)
+			return res, err
+		}
+		b.record(host, b.config.IsFailure(res, err))
+		return res, err
+	}
+}
+
+func (b *breaker) circuit(host string) *hostCircuit {
+	c, ok := b.hosts[host]
+	if !ok {
+		c = &hostCircuit{windowStart: b.now()}
+		b.hosts[host] = c
+	}
+	return c
+}
+
+func (b *breaker) allow(host string) bool {
+	b.mu.Lock()
+	c := b.circuit(host)
+	now := b.now()
+	from := c.state
+
+	switch c.state {
+	case CircuitOpen:
+		if now.Sub(c.openedAt) < b.config.CoolDown {
+			b.mu.Unlock()
+			return false
+		}
+		c.state = CircuitHalfOpen
+		c.probes, c.successes = 0, 0
+		fallthrough
+	case CircuitHalfOpen:
+		if c.probes >= b.config.HalfOpenProbes {
+			to := c.state
+			b.mu.Unlock()
+			b.notify(host, from, to)
+			return false
+		}
+		c.probes++
+	}
+	to := c.state
+	b.mu.Unlock()
+	b.notify(host, from, to)
+	return true
+}
+
+func (b *breaker) record(host string, failed bool) {
+	b.mu.Lock()
+	c := b.circuit(host)
+	now := b.now()
+	from := c.state
+
+	switch c.state {
+	case CircuitHalfOpen:
+		if failed {
+			c.open(now)
+		} else if c.successes++; c.successes >= b.config.HalfOpenProbes {
+			c.close(now)
+		}
+	case CircuitClosed:
+		if now.Sub(c.windowStart) > b.config.Window {
+			c.windowStart, c.requests, c.failures = now, 0, 0
+		}
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if c.requests >= b.config.MinRequests && float64(c.failures)/float64(c.requests) >= b.config.FailureRate {
+			c.open(now)
+		}
+	}
+	to := c.state
+	b.mu.Unlock()
+	b.notify(host, from, to)
+}
+
+// release gives back the probe taken by a request that was not recorded.
+func (b *breaker) release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c := b.circuit(host); c.state == CircuitHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+}
+
+func (b *breaker) notify(host string, from, to CircuitState) {
+	if from != to && b.config.OnStateChange != nil {
+		b.config.OnStateChange(host, from, to)
+	}
+}
+
+func (b *breaker) states() map[string]CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	states := make(map[string]CircuitState, len(b.hosts))
+	for host, c := range b.hosts {
+		states[host] = c.state
+	}
+	return states
+}
+
+func (c *hostCircuit) open(now time.Time) {
+	c.state = CircuitOpen
+	c.openedAt = now
+}
+
+func (c *hostCircuit) close(now time.Time) {
+	c.state = CircuitClosed
+	c.windowStart, c.requests, c.failures = now, 0, 0
+}
@@ -0,0 +1,153 @@
+package goreq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Circuit breaker", func() {
+		var ts *httptest.Server
+		var failing int32
+		var hits int32
+		var host string
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				if atomic.LoadInt32(&failing) == 1 {
+					w.WriteHeader(503)
+					return
+				}
+				w.WriteHeader(200)
+			}))
+			u, _ := url.Parse(ts.URL)
+			host = u.Host
+		})
+
+		g.BeforeEach(func() {
+			atomic.StoreInt32(&failing, 1)
+			atomic.StoreInt32(&hits, 0)
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should open after the failure rate is reached and reject requests", func() {
+			var mu sync.Mutex
+			var changes []CircuitState
+			client := NewClient(Options{CircuitBreaker: &CircuitBreaker{
+				MinRequests: 4,
+				CoolDown:    time.Minute,
+				OnStateChange: func(h string, from, to CircuitState) {
+					mu.Lock()
+					defer mu.Unlock()
+					Expect(h).Should(Equal(host))
+					changes = append(changes, to)
+				},
+			}})
+
+			for i := 0; i < 4; i++ {
+				res, err := client.Do(Request{Uri: ts.URL})
+				Expect(err).Should(BeNil())
+				Expect(res.StatusCode).Should(Equal(503))
+			}
+
+			_, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.(*Error).Err).Should(Equal(ErrCircuitOpen))
+			Expect(err.(*Error).CircuitOpen()).Should(BeTrue())
+			Expect(atomic.LoadInt32(&hits)).Should(Equal(int32(4)))
+			Expect(client.CircuitStates()[host]).Should(Equal(CircuitOpen))
+			Expect(changes).Should(Equal([]CircuitState{CircuitOpen}))
+		})
+
+		g.It("Should not count requests rejected by the client's own limits", func() {
+			atomic.StoreInt32(&failing, 0)
+			client := NewClient(Options{
+				RateLimit:         RateLimit{Rate: 5, Burst: 1},
+				RateLimitFailFast: true,
+				CircuitBreaker:    &CircuitBreaker{MinRequests: 3},
+			})
+
+			_, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+			for i := 0; i < 2; i++ {
+				_, err = client.Do(Request{Uri: ts.URL})
+				Expect(err.(*Error).Err).Should(BeAssignableToTypeOf(&RateLimitError{}))
+			}
+			Expect(client.CircuitStates()[host]).Should(Equal(CircuitClosed))
+		})
+
+		g.It("Should stay closed below the failure rate", func() {
+			client := NewClient(Options{CircuitBreaker: &CircuitBreaker{MinRequests: 4, FailureRate: 0.6}})
+
+			for i := 0; i < 8; i++ {
+				atomic.StoreInt32(&failing, int32(i%2))
+				_, err := client.Do(Request{Uri: ts.URL})
+				Expect(err).Should(BeNil())
+			}
+			Expect(client.CircuitStates()[host]).Should(Equal(CircuitClosed))
+		})
+
+		g.It("Should close again after successful half-open probes", func() {
+			var changes []CircuitState
+			client := NewClient(Options{CircuitBreaker: &CircuitBreaker{
+				MinRequests:    2,
+				CoolDown:       50 * time.Millisecond,
+				HalfOpenProbes: 2,
+				OnStateChange:  func(h string, from, to CircuitState) { changes = append(changes, to) },
+			}})
+			clock := newFakeClock()
+			client.breaker.now = clock.now
+
+			client.Do(Request{Uri: ts.URL})
+			client.Do(Request{Uri: ts.URL})
+			Expect(client.CircuitStates()[host]).Should(Equal(CircuitOpen))
+
+			clock.advance(40 * time.Millisecond)
+			_, err := client.Do(Request{Uri: ts.URL})
+			Expect(err.(*Error).Err).Should(Equal(ErrCircuitOpen))
+
+			clock.advance(10 * time.Millisecond)
+			atomic.StoreInt32(&failing, 0)
+
+			_, err = client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+			Expect(client.CircuitStates()[host]).Should(Equal(CircuitHalfOpen))
+			_, err = client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+			Expect(client.CircuitStates()[host]).Should(Equal(CircuitClosed))
+			Expect(changes).Should(Equal([]CircuitState{CircuitOpen, CircuitHalfOpen, CircuitClosed}))
+		})
+
+		g.It("Should open again when a probe fails", func() {
+			client := NewClient(Options{CircuitBreaker: &CircuitBreaker{MinRequests: 2, CoolDown: 50 * time.Millisecond}})
+			clock := newFakeClock()
+			client.breaker.now = clock.now
+
+			client.Do(Request{Uri: ts.URL})
+			client.Do(Request{Uri: ts.URL})
+			clock.advance(50 * time.Millisecond)
+
+			_, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+			Expect(client.CircuitStates()[host]).Should(Equal(CircuitOpen))
+
+			_, err = client.Do(Request{Uri: ts.URL})
+			Expect(err.(*Error).Err).Should(Equal(ErrCircuitOpen))
+		})
+	})
+}
@@ -0,0 +1,140 @@
+package goreq
+
+import (
+	"context"
+	"net/http"
+)
+
+// RequestBuilder builds a Request through chained calls and sends it with
+// the Client that created it.
+type RequestBuilder struct {
+	client  Client
+	request Request
+}
+
+// Request starts a RequestBuilder for the given method and uri.
+func (client Client) Request(method, uri string) *RequestBuilder {
+	return &RequestBuilder{client: client, request: Request{Method: method, Uri: uri}}
+}
+
+// Get starts a GET RequestBuilder.
+func (client Client) Get(uri string) *RequestBuilder {
+	return client.Request("GET", uri)
+}
+
+// Post starts a POST RequestBuilder.
+func (client Client) Post(uri string) *RequestBuilder {
+	return client.Request("POST", uri)
+}
+
+// Put starts a PUT RequestBuilder.
+func (client Client) Put(uri string) *RequestBuilder {
+	return client.Request("PUT", uri)
+}
+
+// Patch starts a PATCH RequestBuilder.
+func (client Client) Patch(uri string) *RequestBuilder {
+	return client.Request("PATCH", uri)
+}
+
+// Delete starts a DELETE RequestBuilder.
+func (client Client) Delete(uri string) *RequestBuilder {
+	return client.Request("DELETE", uri)
+}
+
+// Head starts a HEAD RequestBuilder.
+func (client Client) Head(uri string) *RequestBuilder {
+	return client.Request("HEAD", uri)
+}
+
+// Query sets the query string, accepting the same values as Request.QueryString.
+func (b *RequestBuilder) Query(query interface{}) *RequestBuilder {
+	b.request.QueryString = query
+	return b
+}
+
+// Header adds a header to the request.
+func (b *RequestBuilder) Header(name, value string) *RequestBuilder {
+	b.request.AddHeader(name, value)
+	return b
+}
+
+// Cookie adds a cookie to the request.
+func (b *RequestBuilder) Cookie(cookie *http.Cookie) *RequestBuilder {
+	b.request.AddCookie(cookie)
+	return b
+}
+
+// Body sets the request body, accepting the same values as Request.Body.
+func (b *RequestBuilder) Body(body interface{}) *RequestBuilder {
+	b.request.Body = body
+	return b
+}
+
+// JSON sets the request body and the application/json content type.
+func (b *RequestBuilder) JSON(body interface{}) *RequestBuilder {
+	b.request.Body = body
+	b.request.ContentType = "application/json"
+	return b
+}
+
+// ContentType sets the Content-Type header.
+func (b *RequestBuilder) ContentType(contentType string) *RequestBuilder {
+	b.request.ContentType = contentType
+	return b
+}
+
+// Accept sets the Accept header.
+func (b *RequestBuilder) Accept(accept string) *RequestBuilder {
+	b.request.Accept = accept
+	return b
+}
+
+// Host overrides the Host header.
+func (b *RequestBuilder) Host(host string) *RequestBuilder {
+	b.request.Host = host
+	return b
+}
+
+// UserAgent sets the User-Agent header.
+func (b *RequestBuilder) UserAgent(userAgent string) *RequestBuilder {
+	b.request.UserAgent = userAgent
+	return b
+}
+
+// BasicAuth sets the basic auth credentials.
+func (b *RequestBuilder) BasicAuth(username, password string) *RequestBuilder {
+	b.request.BasicAuthUsername = username
+	b.request.BasicAuthPassword = password
+	return b
+}
+
+// Compression sets the compression used for the body and the response.
+func (b *RequestBuilder) Compression(c *compression) *RequestBuilder {
+	b.request.Compression = c
+	return b
+}
+
+// Debug dumps the request to the log before sending it.
+func (b *RequestBuilder) Debug() *RequestBuilder {
+	b.request.ShowDebug = true
+	return b
+}
+
+// OnBeforeRequest sets the hook called before the request is sent.
+func (b *RequestBuilder) OnBeforeRequest(f func(goreq *Request, httpreq *http.Request)) *RequestBuilder {
+	b.request.OnBeforeRequest = f
+	return b
+}
+
+// Build returns the Request built so far.
+func (b *RequestBuilder) Build() Request {
+	return b.request
+}
+
+// Do sends the request with ctx using the Client that created the builder.
+func (b *RequestBuilder) Do(ctx context.Context) (*Response, error) {
+	request := b.request
+	request.Context = ctx
+	return b.client.Do(request)
+}
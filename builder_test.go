@@ -0,0 +1,92 @@
+package goreq
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestRequestBuilder(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Request builder", func() {
+		var ts *httptest.Server
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/slow" {
+					time.Sleep(200 * time.Millisecond)
+				}
+				body, _ := ioutil.ReadAll(r.Body)
+				cookie := &http.Cookie{}
+				if c, err := r.Cookie("c1"); err == nil {
+					cookie = c
+				}
+				w.WriteHeader(200)
+				fmt.Fprintf(w, "%s %s %s %s %s %s", r.Method, r.URL.RawQuery, r.Header.Get("X-Custom"), r.Header.Get("Content-Type"), cookie.Value, body)
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should build the same Request as a struct literal", func() {
+			client := NewClient(Options{})
+			request := client.Post(ts.URL).
+				Query(Query{Limit: 3, Skip: 5}).
+				Header("X-Custom", "value").
+				JSON(map[string]string{"foo": "bar"}).
+				Build()
+
+			Expect(request.Method).Should(Equal("POST"))
+			Expect(request.Uri).Should(Equal(ts.URL))
+			Expect(request.QueryString).Should(Equal(Query{Limit: 3, Skip: 5}))
+			Expect(request.ContentType).Should(Equal("application/json"))
+			Expect(request.headers).Should(Equal([]headerTuple{{name: "X-Custom", value: "value"}}))
+		})
+
+		g.It("Should send the built request", func() {
+			client := NewClient(Options{})
+			res, err := client.Put(ts.URL).
+				Query(Query{Limit: 3, Skip: 5}).
+				Header("X-Custom", "value").
+				Cookie(&http.Cookie{Name: "c1", Value: "v1"}).
+				JSON(map[string]string{"foo": "bar"}).
+				Do(context.Background())
+
+			Expect(err).Should(BeNil())
+			str, _ := res.Body.ToString()
+			Expect(str).Should(Equal(`PUT limit=3&skip=5 value application/json v1 {"foo":"bar"}`))
+		})
+
+		g.It("Should use the method of each convenience constructor", func() {
+			client := NewClient(Options{})
+			Expect(client.Get("/").Build().Method).Should(Equal("GET"))
+			Expect(client.Post("/").Build().Method).Should(Equal("POST"))
+			Expect(client.Put("/").Build().Method).Should(Equal("PUT"))
+			Expect(client.Patch("/").Build().Method).Should(Equal("PATCH"))
+			Expect(client.Delete("/").Build().Method).Should(Equal("DELETE"))
+			Expect(client.Head("/").Build().Method).Should(Equal("HEAD"))
+		})
+
+		g.It("Should honour the context passed to Do", func() {
+			client := NewClient(Options{})
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			_, err := client.Get(ts.URL + "/slow").Do(ctx)
+
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.(*Error).Timeout()).Should(BeTrue())
+		})
+	})
+}
@@ -0,0 +1,148 @@
+package goreq
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BulkheadError is returned when a request cannot get an in-flight slot for
+// its host, either because the queue is full or because it waited longer
+// than Options.QueueTimeout.
+type BulkheadError struct {
+	Host    string
+	Timeout bool
+}
+
+func (e *BulkheadError) Error() string {
+	if e.Timeout {
+		return fmt.Sprintf("GoReq: timed out waiting for a connection slot to %s", e.Host)
+	}
+	return fmt.Sprintf("GoReq: too many requests queued for %s", e.Host)
+}
+
+// HostStats reports the requests in flight and waiting for a slot for a host.
+type HostStats struct {
+	InFlight int
+	Queued   int
+}
+
+type bulkhead struct {
+	maxConcurrent int
+	maxQueued     int
+	queueTimeout  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostBulkhead
+}
+
+type hostBulkhead struct {
+	slots  chan struct{}
+	queued int32
+}
+
+func newBulkhead(options Options) *bulkhead {
+	if options.MaxConcurrentPerHost <= 0 {
+		return nil
+	}
+	return &bulkhead{
+		maxConcurrent: options.MaxConcurrentPerHost,
+		maxQueued:     options.MaxQueuedPerHost,
+		queueTimeout:  options.QueueTimeout,
+		hosts:         map[string]*hostBulkhead{},
+	}
+}
+
+func (b *bulkhead) host(host string) *hostBulkhead {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h, ok := b.hosts[host]
+	if !ok {
+		h = &hostBulkhead{slots: make(chan struct{}, b.maxConcurrent)}
+		b.hosts[host] = h
+	}
+	return h
+}
+
+func (b *bulkhead) stats() map[string]HostStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := make(map[string]HostStats, len(b.hosts))
+	for name, h := range b.hosts {
+		stats[name] = HostStats{InFlight: len(h.slots), Queued: int(atomic.LoadInt32(&h.queued))}
+	}
+	return stats
+}
+
+func (b *bulkhead) acquire(req *http.Request, h *hostBulkhead) error {
+	select {
+	case h.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if queued := atomic.AddInt32(&h.queued, 1); b.maxQueued > 0 && int(queued) > b.maxQueued {
+		atomic.AddInt32(&h.queued, -1)
+		return &BulkheadError{Host: req.URL.Host}
+	}
+	defer atomic.AddInt32(&h.queued, -1)
+
+	var timeout <-chan time.Time
+	if b.queueTimeout > 0 {
+		timer := time.NewTimer(b.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case h.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		return &BulkheadError{Host: req.URL.Host, Timeout: true}
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// wrap holds a slot from the moment the request is sent until its response
+// body is read to the end or closed.
+func (b *bulkhead) wrap(next roundTripFunc) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		h := b.host(req.URL.Host)
+		if err := b.acquire(req, h); err != nil {
+			return nil, err
+		}
+		release := func() { <-h.slots }
+
+		res, err := next(req)
+		if err != nil || res.Body == nil {
+			release()
+			return res, err
+		}
+		res.Body = &releaseBody{reader: res.Body, release: release}
+		return res, nil
+	}
+}
+
+type releaseBody struct {
+	reader  io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releaseBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releaseBody) Close() error {
+	err := b.reader.Close()
+	b.once.Do(b.release)
+	return err
+}
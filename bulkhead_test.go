@@ -0,0 +1,109 @@
+package goreq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestBulkhead(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Concurrency limit per host", func() {
+		var ts *httptest.Server
+		var inFlight, maxInFlight int32
+		var host string
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&inFlight, 1)
+				for {
+					max := atomic.LoadInt32(&maxInFlight)
+					if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+						break
+					}
+				}
+				time.Sleep(50 * time.Millisecond)
+				atomic.AddInt32(&inFlight, -1)
+				w.Write([]byte("ok"))
+			}))
+			u, _ := url.Parse(ts.URL)
+			host = u.Host
+		})
+
+		g.BeforeEach(func() {
+			atomic.StoreInt32(&maxInFlight, 0)
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should cap in-flight requests and queue the rest", func() {
+			client := NewClient(Options{MaxConcurrentPerHost: 2})
+
+			var wg sync.WaitGroup
+			for i := 0; i < 6; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					res, err := client.Do(Request{Uri: ts.URL})
+					Expect(err).Should(BeNil())
+					res.Body.ToString()
+				}()
+			}
+			wg.Wait()
+
+			Expect(atomic.LoadInt32(&maxInFlight)).Should(Equal(int32(2)))
+			Expect(client.HostStats()[host]).Should(Equal(HostStats{}))
+		})
+
+		g.It("Should hold the slot until the body is closed", func() {
+			client := NewClient(Options{MaxConcurrentPerHost: 1, QueueTimeout: 20 * time.Millisecond})
+
+			res, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+			Expect(client.HostStats()[host].InFlight).Should(Equal(1))
+
+			_, err = client.Do(Request{Uri: ts.URL})
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.(*Error).Err.(*BulkheadError).Timeout).Should(BeTrue())
+
+			res.Body.Close()
+			Expect(client.HostStats()[host].InFlight).Should(Equal(0))
+			_, err = client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+		})
+
+		g.It("Should reject requests when the queue is full", func() {
+			client := NewClient(Options{MaxConcurrentPerHost: 1, MaxQueuedPerHost: 1})
+
+			res, _ := client.Do(Request{Uri: ts.URL})
+
+			queued := make(chan error)
+			go func() {
+				res, err := client.Do(Request{Uri: ts.URL})
+				if err == nil {
+					res.Body.Close()
+				}
+				queued <- err
+			}()
+			Eventually(func() int { return client.HostStats()[host].Queued }).Should(Equal(1))
+
+			_, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.(*Error).Err.(*BulkheadError).Timeout).Should(BeFalse())
+
+			res.Body.Close()
+			Expect(<-queued).Should(BeNil())
+		})
+	})
+}
@@ -0,0 +1,421 @@
+package goreq
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStatusHeader is set on every response that went through the cache,
+// with one of CacheHit, CacheMiss, CacheRevalidated or CacheStale.
+const CacheStatusHeader = "X-Goreq-Cache"
+
+const (
+	// CacheHit means the response was fresh and served from the cache.
+	CacheHit = "HIT"
+	// CacheMiss means the response came from the server.
+	CacheMiss = "MISS"
+	// CacheRevalidated means the server confirmed the cached response with 304.
+	CacheRevalidated = "REVALIDATED"
+	// CacheStale means a stale response was served while being revalidated
+	// in the background (stale-while-revalidate).
+	CacheStale = "STALE"
+)
+
+// maxCacheableSize is the largest body that is kept while being read to
+// be stored in the cache.
+const maxCacheableSize = 16 << 20
+
+// CachedResponse is what a CacheStore keeps for each URL. Entries must be
+// treated as immutable once stored.
+type CachedResponse struct {
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+	RequestTime  time.Time
+	ResponseTime time.Time
+	// Vary holds the request header values selected by the response Vary header.
+	Vary http.Header
+}
+
+func (e *CachedResponse) size() int64 {
+	size := int64(len(e.Body))
+	for name, values := range e.Header {
+		for _, value := range values {
+			size += int64(len(name) + len(value))
+		}
+	}
+	return size
+}
+
+// CacheStore stores cached responses by key.
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, entry *CachedResponse)
+	Delete(key string)
+}
+
+// CacheStatus returns the CacheStatusHeader value of the response, or an
+// empty string when the client has no cache.
+func (r *Response) CacheStatus() string {
+	if r.Response == nil {
+		return ""
+	}
+	return r.Header.Get(CacheStatusHeader)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+// httpCache implements a private HTTP cache following RFC 9111 in front of
+// the client transport.
+type httpCache struct {
+	store CacheStore
+	now   func() time.Time
+
+	mu           sync.Mutex
+	revalidating map[string]bool
+}
+
+func newHTTPCache(store CacheStore) *httpCache {
+	return &httpCache{store: store, now: time.Now, revalidating: map[string]bool{}}
+}
+
+// cacheKey keys entries by URL and by the credentials of the request, so
+// callers sharing a client never get each other's responses. Credentials
+// are hashed to keep them out of the store.
+func cacheKey(req *http.Request) string {
+	key := "GET " + req.URL.String()
+	auth, cookie := req.Header["Authorization"], req.Header["Cookie"]
+	if len(auth) == 0 && len(cookie) == 0 {
+		return key
+	}
+	sum := sha256.Sum256([]byte(strings.Join(auth, ",") + "\n" + strings.Join(cookie, "; ")))
+	return key + " " + hex.EncodeToString(sum[:])
+}
+
+func (c *httpCache) do(req *http.Request, next roundTripFunc) (*http.Response, error) {
+	key := cacheKey(req)
+
+	if req.Method != "GET" {
+		res, err := next(req)
+		if err == nil && req.Method != "HEAD" && req.Method != "OPTIONS" && req.Method != "TRACE" && res.StatusCode < 400 {
+			c.store.Delete(key)
+		}
+		return res, err
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		return next(req)
+	}
+
+	entry, ok := c.store.Get(key)
+	if ok && !varyMatches(entry, req) {
+		ok = false
+	}
+	if !ok {
+		if _, only := reqCC["only-if-cached"]; only {
+			return syntheticResponse(req, http.StatusGatewayTimeout), nil
+		}
+		return c.fetch(req, next, CacheMiss)
+	}
+
+	resCC := parseCacheControl(entry.Header)
+	age := c.age(entry)
+	lifetime := freshnessLifetime(entry, resCC)
+	_, reqNoCache := reqCC["no-cache"]
+	_, resNoCache := resCC["no-cache"]
+	mustRevalidate := reqNoCache || resNoCache
+
+	fresh := age < lifetime && !mustRevalidate
+	if maxAge, ok := cacheDuration(reqCC, "max-age"); ok && age > maxAge {
+		fresh = false
+	}
+	if fresh {
+		return entry.response(req, age, CacheHit), nil
+	}
+
+	if swr, ok := cacheDuration(resCC, "stale-while-revalidate"); ok && !mustRevalidate && age < lifetime+swr {
+		c.revalidateInBackground(key, req, entry, next)
+		return entry.response(req, age, CacheStale), nil
+	}
+
+	return c.revalidate(key, req, entry, next)
+}
+
+// fetch sends req and arranges for a cacheable response to be stored once
+// its body has been fully read.
+func (c *httpCache) fetch(req *http.Request, next roundTripFunc, status string) (*http.Response, error) {
+	requestTime := c.now()
+	res, err := next(req)
+	if err != nil {
+		return res, err
+	}
+	res.Header.Set(CacheStatusHeader, status)
+	c.storeOnRead(req, res, requestTime)
+	return res, nil
+}
+
+func (c *httpCache) revalidate(key string, req *http.Request, entry *CachedResponse, next roundTripFunc) (*http.Response, error) {
+	etag := entry.Header.Get("ETag")
+	lastModified := entry.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return c.fetch(req, next, CacheMiss)
+	}
+
+	conditional := req.Clone(req.Context())
+	if etag != "" && conditional.Header.Get("If-None-Match") == "" {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" && conditional.Header.Get("If-Modified-Since") == "" {
+		conditional.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	requestTime := c.now()
+	res, err := next(conditional)
+	if err != nil {
+		return res, err
+	}
+	if res.StatusCode != http.StatusNotModified {
+		res.Header.Set(CacheStatusHeader, CacheMiss)
+		c.storeOnRead(req, res, requestTime)
+		return res, nil
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	updated := &CachedResponse{
+		StatusCode:   entry.StatusCode,
+		Header:       entry.Header.Clone(),
+		Body:         entry.Body,
+		RequestTime:  requestTime,
+		ResponseTime: c.now(),
+		Vary:         entry.Vary,
+	}
+	for name, values := range res.Header {
+		if name == "Content-Length" {
+			continue
+		}
+		updated.Header[name] = values
+	}
+	c.store.Set(key, updated)
+	return updated.response(req, c.age(updated), CacheRevalidated), nil
+}
+
+func (c *httpCache) revalidateInBackground(key string, req *http.Request, entry *CachedResponse, next roundTripFunc) {
+	c.mu.Lock()
+	if c.revalidating[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.revalidating[key] = true
+	c.mu.Unlock()
+
+	background := req.Clone(context.Background())
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.revalidating, key)
+			c.mu.Unlock()
+		}()
+		res, err := c.revalidate(key, background, entry, next)
+		if err == nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+	}()
+}
+
+func (c *httpCache) storeOnRead(req *http.Request, res *http.Response, requestTime time.Time) {
+	if !isCacheable(res) {
+		return
+	}
+	entry := &CachedResponse{
+		StatusCode:  res.StatusCode,
+		Header:      res.Header.Clone(),
+		RequestTime: requestTime,
+		Vary:        http.Header{},
+	}
+	entry.Header.Del(CacheStatusHeader)
+	for _, name := range varyHeaders(res.Header) {
+		entry.Vary[name] = req.Header[name]
+	}
+	key := cacheKey(req)
+	res.Body = &cacheBody{
+		reader: res.Body,
+		done: func(body []byte) {
+			entry.Body = body
+			entry.ResponseTime = c.now()
+			c.store.Set(key, entry)
+		},
+	}
+}
+
+// cacheBody hands the body over to done once it has been read to the end.
+type cacheBody struct {
+	reader io.ReadCloser
+	buffer bytes.Buffer
+	done   func(body []byte)
+	failed bool
+}
+
+func (b *cacheBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if !b.failed {
+		b.buffer.Write(p[:n])
+		if b.buffer.Len() > maxCacheableSize {
+			b.failed = true
+			b.buffer = bytes.Buffer{}
+		}
+		if err == io.EOF {
+			b.failed = true
+			b.done(b.buffer.Bytes())
+		} else if err != nil {
+			b.failed = true
+		}
+	}
+	return n, err
+}
+
+func (b *cacheBody) Close() error {
+	return b.reader.Close()
+}
+
+func (e *CachedResponse) response(req *http.Request, age time.Duration, status string) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	header.Set(CacheStatusHeader, status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func syntheticResponse(req *http.Request, status int) *http.Response {
+	return (&CachedResponse{StatusCode: status, Header: http.Header{}}).response(req, 0, CacheMiss)
+}
+
+// age implements the age calculation of RFC 9111 section 4.2.3.
+func (c *httpCache) age(e *CachedResponse) time.Duration {
+	date, err := http.ParseTime(e.Header.Get("Date"))
+	if err != nil {
+		date = e.ResponseTime
+	}
+	apparentAge := e.ResponseTime.Sub(date)
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+	ageValue, _ := strconv.ParseInt(e.Header.Get("Age"), 10, 64)
+	correctedAge := time.Duration(ageValue)*time.Second + e.ResponseTime.Sub(e.RequestTime)
+	if apparentAge > correctedAge {
+		correctedAge = apparentAge
+	}
+	return correctedAge + c.now().Sub(e.ResponseTime)
+}
+
+// freshnessLifetime implements RFC 9111 section 4.2.1, using the 10%
+// heuristic of section 4.2.2 when only Last-Modified is available.
+func freshnessLifetime(e *CachedResponse, cc map[string]string) time.Duration {
+	if maxAge, ok := cacheDuration(cc, "max-age"); ok {
+		return maxAge
+	}
+	date, err := http.ParseTime(e.Header.Get("Date"))
+	if err != nil {
+		date = e.ResponseTime
+	}
+	if expiresHeader := e.Header.Get("Expires"); expiresHeader != "" {
+		expires, err := http.ParseTime(expiresHeader)
+		if err != nil {
+			return 0
+		}
+		return expires.Sub(date)
+	}
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil {
+		return date.Sub(lastModified) / 10
+	}
+	return 0
+}
+
+func isCacheable(res *http.Response) bool {
+	switch res.StatusCode {
+	case 200, 203, 204, 300, 301, 308, 404, 405, 410, 414, 501:
+	default:
+		return false
+	}
+	cc := parseCacheControl(res.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if res.Header.Get("Vary") == "*" {
+		return false
+	}
+	_, maxAge := cc["max-age"]
+	return maxAge || res.Header.Get("Expires") != "" || res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != ""
+}
+
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, vary := range h["Vary"] {
+		for _, name := range strings.Split(vary, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+func varyMatches(e *CachedResponse, req *http.Request) bool {
+	for _, name := range varyHeaders(e.Header) {
+		if strings.Join(e.Vary[name], ",") != strings.Join(req.Header[name], ",") {
+			return false
+		}
+	}
+	return true
+}
+
+func parseCacheControl(h http.Header) map[string]string {
+	cc := map[string]string{}
+	for _, header := range h["Cache-Control"] {
+		for _, directive := range strings.Split(header, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, value := directive, ""
+			if i := strings.Index(directive, "="); i >= 0 {
+				name, value = directive[:i], strings.Trim(directive[i+1:], `"`)
+			}
+			cc[strings.ToLower(name)] = value
+		}
+	}
+	return cc
+}
+
+func cacheDuration(cc map[string]string, directive string) (time.Duration, bool) {
+	value, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
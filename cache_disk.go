@@ -0,0 +1,229 @@
+package goreq
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const diskCacheTempPrefix = ".tmp-"
+
+// DiskCache is a CacheStore that keeps one file per entry under a
+// directory, named after the sha256 of the key. Writes are atomic, the
+// least recently used files are evicted once the directory exceeds its
+// size in bytes, and unreadable or corrupted files are discarded as misses.
+// It survives process restarts, which makes it suitable for CLI tools.
+type DiskCache struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+	size    int64
+	lru     *list.List
+	items   map[string]*list.Element
+}
+
+type diskCacheItem struct {
+	name string
+	size int64
+}
+
+type diskCacheFile struct {
+	modTime time.Time
+	item    *diskCacheItem
+}
+
+// NewDiskCache returns a DiskCache storing up to maxSize bytes under dir,
+// creating it when needed and indexing the entries already there.
+func NewDiskCache(dir string, maxSize int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	d := &DiskCache{dir: dir, maxSize: maxSize, lru: list.New(), items: map[string]*list.Element{}}
+
+	var files []diskCacheFile
+	root := filepath.Clean(dir)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		// Only the files of the entry subdirectories belong to the cache;
+		// anything else in the directory is left alone.
+		sub := filepath.Dir(path)
+		if filepath.Dir(sub) != root || len(filepath.Base(sub)) != 2 {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), diskCacheTempPrefix) {
+			os.Remove(path)
+			return nil
+		}
+		if !isDiskCacheName(info.Name()) || info.Name()[:2] != filepath.Base(sub) {
+			return nil
+		}
+		files = append(files, diskCacheFile{modTime: info.ModTime(), item: &diskCacheItem{name: info.Name(), size: info.Size()}})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for _, f := range files {
+		d.items[f.item.name] = d.lru.PushBack(f.item)
+		d.size += f.item.size
+	}
+	d.evict()
+	return d, nil
+}
+
+func (d *DiskCache) name(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// isDiskCacheName reports whether name is an entry file name: the
+// lowercase hex sha256 of a key.
+func isDiskCacheName(name string) bool {
+	if len(name) != 2*sha256.Size || strings.ToLower(name) != name {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+func (d *DiskCache) path(name string) string {
+	return filepath.Join(d.dir, name[:2], name)
+}
+
+// Get returns the entry stored under key. Corrupted entries are removed.
+func (d *DiskCache) Get(key string) (*CachedResponse, bool) {
+	name := d.name(key)
+	data, err := ioutil.ReadFile(d.path(name))
+	if err != nil {
+		return nil, false
+	}
+
+	entry, ok := decodeDiskEntry(data)
+	if !ok {
+		d.mu.Lock()
+		d.remove(name)
+		d.mu.Unlock()
+		return nil, false
+	}
+
+	d.mu.Lock()
+	if element, ok := d.items[name]; ok {
+		d.lru.MoveToFront(element)
+	}
+	d.mu.Unlock()
+	now := time.Now()
+	os.Chtimes(d.path(name), now, now)
+	return entry, true
+}
+
+// Set writes entry under key, evicting old entries as needed. Entries
+// larger than the whole cache are not stored.
+func (d *DiskCache) Set(key string, entry *CachedResponse) {
+	data, err := encodeDiskEntry(entry)
+	if err != nil || int64(len(data)) > d.maxSize {
+		d.Delete(key)
+		return
+	}
+
+	name := d.name(key)
+	path := d.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), diskCacheTempPrefix)
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if element, ok := d.items[name]; ok {
+		d.size -= element.Value.(*diskCacheItem).size
+		d.lru.Remove(element)
+	}
+	d.items[name] = d.lru.PushFront(&diskCacheItem{name: name, size: int64(len(data))})
+	d.size += int64(len(data))
+	d.evict()
+}
+
+// Delete removes the entry stored under key.
+func (d *DiskCache) Delete(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.remove(d.name(key))
+}
+
+// Size returns the number of bytes used by the entries in the cache.
+func (d *DiskCache) Size() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.size
+}
+
+func (d *DiskCache) evict() {
+	for d.size > d.maxSize && d.lru.Len() > 0 {
+		d.remove(d.lru.Back().Value.(*diskCacheItem).name)
+	}
+}
+
+func (d *DiskCache) remove(name string) {
+	os.Remove(d.path(name))
+	element, ok := d.items[name]
+	if !ok {
+		return
+	}
+	d.lru.Remove(element)
+	delete(d.items, name)
+	d.size -= element.Value.(*diskCacheItem).size
+}
+
+// encodeDiskEntry prefixes the gob encoded entry with its sha256 so
+// truncated or corrupted files can be detected.
+func encodeDiskEntry(entry *CachedResponse) ([]byte, error) {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(entry); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(payload.Bytes())
+	return append(sum[:], payload.Bytes()...), nil
+}
+
+func decodeDiskEntry(data []byte) (*CachedResponse, bool) {
+	if len(data) < sha256.Size {
+		return nil, false
+	}
+	payload := data[sha256.Size:]
+	if sum := sha256.Sum256(payload); !bytes.Equal(sum[:], data[:sha256.Size]) {
+		return nil, false
+	}
+	entry := &CachedResponse{}
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(entry); err != nil {
+		return nil, false
+	}
+	return entry, true
+}
@@ -0,0 +1,151 @@
+package goreq
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestDiskCache(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Disk cache store", func() {
+		var dir string
+
+		entry := func(body string) *CachedResponse {
+			return &CachedResponse{StatusCode: 200, Header: http.Header{"Etag": {`"v1"`}}, Body: []byte(body)}
+		}
+
+		g.BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "goreq-cache")
+		})
+
+		g.AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		g.It("Should store and load entries across instances", func() {
+			cache, err := NewDiskCache(dir, 1<<20)
+			Expect(err).Should(BeNil())
+			cache.Set("GET http://foo", entry("bar"))
+
+			cache, err = NewDiskCache(dir, 1<<20)
+			Expect(err).Should(BeNil())
+			e, ok := cache.Get("GET http://foo")
+			Expect(ok).Should(BeTrue())
+			Expect(string(e.Body)).Should(Equal("bar"))
+			Expect(e.StatusCode).Should(Equal(200))
+			Expect(e.Header.Get("ETag")).Should(Equal(`"v1"`))
+			Expect(cache.Size() > 0).Should(BeTrue())
+		})
+
+		g.It("Should delete entries", func() {
+			cache, _ := NewDiskCache(dir, 1<<20)
+			cache.Set("a", entry("a"))
+			cache.Delete("a")
+
+			_, ok := cache.Get("a")
+			Expect(ok).Should(BeFalse())
+			Expect(cache.Size()).Should(Equal(int64(0)))
+		})
+
+		g.It("Should evict least recently used entries over the size limit", func() {
+			cache, _ := NewDiskCache(dir, 1<<20)
+			cache.Set("probe", entry(strings.Repeat("x", 1000)))
+			size := cache.Size()
+			cache.Delete("probe")
+
+			cache, _ = NewDiskCache(dir, size*2+size/2)
+			cache.Set("a", entry(strings.Repeat("a", 1000)))
+			cache.Set("b", entry(strings.Repeat("b", 1000)))
+			cache.Get("a")
+			cache.Set("c", entry(strings.Repeat("c", 1000)))
+
+			_, ok := cache.Get("b")
+			Expect(ok).Should(BeFalse())
+			_, ok = cache.Get("a")
+			Expect(ok).Should(BeTrue())
+			_, ok = cache.Get("c")
+			Expect(ok).Should(BeTrue())
+		})
+
+		g.It("Should recover from corrupted files", func() {
+			cache, _ := NewDiskCache(dir, 1<<20)
+			cache.Set("a", entry("a"))
+
+			path := cache.path(cache.name("a"))
+			data, _ := ioutil.ReadFile(path)
+			ioutil.WriteFile(path, data[:len(data)-3], 0644)
+
+			_, ok := cache.Get("a")
+			Expect(ok).Should(BeFalse())
+			_, err := os.Stat(path)
+			Expect(os.IsNotExist(err)).Should(BeTrue())
+			Expect(cache.Size()).Should(Equal(int64(0)))
+		})
+
+		g.It("Should clean up leftover temporary files", func() {
+			os.MkdirAll(filepath.Join(dir, "ab"), 0755)
+			tmp := filepath.Join(dir, "ab", ".tmp-123")
+			ioutil.WriteFile(tmp, []byte("partial"), 0644)
+
+			cache, _ := NewDiskCache(dir, 1<<20)
+			Expect(cache.Size()).Should(Equal(int64(0)))
+			_, err := os.Stat(tmp)
+			Expect(os.IsNotExist(err)).Should(BeTrue())
+		})
+
+		g.It("Should ignore files that are not cache entries", func() {
+			foreign := []string{
+				filepath.Join(dir, "a"),
+				filepath.Join(dir, "notes.txt"),
+				filepath.Join(dir, "ab", "notes.txt"),
+				filepath.Join(dir, "ab", strings.Repeat("cd", 32)),
+				filepath.Join(dir, "nested", "ab", strings.Repeat("ab", 32)),
+			}
+			for _, path := range foreign {
+				os.MkdirAll(filepath.Dir(path), 0755)
+				ioutil.WriteFile(path, []byte("foreign data"), 0644)
+			}
+
+			cache, err := NewDiskCache(dir, 4)
+			Expect(err).Should(BeNil())
+			Expect(cache.Size()).Should(Equal(int64(0)))
+			for _, path := range foreign {
+				_, err := os.Stat(path)
+				Expect(err).Should(BeNil())
+			}
+		})
+
+		g.It("Should work as the client cache", func() {
+			hits := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				w.Header().Set("Cache-Control", "max-age=60")
+				fmt.Fprint(w, "cached")
+			}))
+			defer ts.Close()
+
+			cache, _ := NewDiskCache(dir, 1<<20)
+			res, _ := NewClient(Options{Cache: cache}).Do(Request{Uri: ts.URL})
+			res.Body.ToString()
+
+			cache, _ = NewDiskCache(dir, 1<<20)
+			res, err := NewClient(Options{Cache: cache}).Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+			str, _ := res.Body.ToString()
+			Expect(str).Should(Equal("cached"))
+			Expect(res.CacheStatus()).Should(Equal(CacheHit))
+			Expect(hits).Should(Equal(1))
+		})
+	})
+}
@@ -0,0 +1,94 @@
+package goreq
+
+import (
+	"container/list"
+	"sync"
+)
+
+// MemoryCache is an in-memory CacheStore that evicts the least recently
+// used entries once the stored responses exceed its size in bytes.
+type MemoryCache struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	lru     *list.List
+	items   map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CachedResponse
+	size  int64
+}
+
+// NewMemoryCache returns a MemoryCache holding up to maxSize bytes.
+func NewMemoryCache(maxSize int64) *MemoryCache {
+	return &MemoryCache{
+		maxSize: maxSize,
+		lru:     list.New(),
+		items:   map[string]*list.Element{},
+	}
+}
+
+// Get returns the entry stored under key.
+func (m *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	m.lru.MoveToFront(element)
+	return element.Value.(*memoryCacheItem).entry, true
+}
+
+// Set stores entry under key, evicting old entries as needed. Entries
+// larger than the whole cache are not stored.
+func (m *MemoryCache) Set(key string, entry *CachedResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(key)
+	size := entry.size()
+	if size > m.maxSize {
+		return
+	}
+	m.items[key] = m.lru.PushFront(&memoryCacheItem{key: key, entry: entry, size: size})
+	m.size += size
+
+	for m.size > m.maxSize {
+		m.remove(m.lru.Back().Value.(*memoryCacheItem).key)
+	}
+}
+
+// Delete removes the entry stored under key.
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(key)
+}
+
+// Len returns the number of entries in the cache.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+// Size returns the number of bytes used by the entries in the cache.
+func (m *MemoryCache) Size() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.size
+}
+
+func (m *MemoryCache) remove(key string) {
+	element, ok := m.items[key]
+	if !ok {
+		return
+	}
+	m.lru.Remove(element)
+	delete(m.items, key)
+	m.size -= element.Value.(*memoryCacheItem).size
+}
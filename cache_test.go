@@ -0,0 +1,245 @@
+package goreq
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestCache(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("HTTP cache", func() {
+		var ts *httptest.Server
+		var mu sync.Mutex
+		var hits map[string]int
+		var conditional map[string]int
+
+		count := func(path string) int {
+			mu.Lock()
+			defer mu.Unlock()
+			return hits[path]
+		}
+
+		get := func(client Client, uri string, headers ...string) (*Response, string) {
+			request := Request{Uri: uri}
+			for i := 0; i < len(headers); i += 2 {
+				request.AddHeader(headers[i], headers[i+1])
+			}
+			res, err := client.Do(request)
+			Expect(err).Should(BeNil())
+			str, _ := res.Body.ToString()
+			return res, str
+		}
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				hits[r.URL.Path]++
+				n := hits[r.URL.Path]
+				if r.Header.Get("If-None-Match") != "" {
+					conditional[r.URL.Path]++
+				}
+				mu.Unlock()
+
+				switch r.URL.Path {
+				case "/fresh":
+					w.Header().Set("Cache-Control", "max-age=60")
+				case "/nostore":
+					w.Header().Set("Cache-Control", "no-store")
+				case "/etag":
+					w.Header().Set("Cache-Control", "no-cache")
+					w.Header().Set("ETag", `"v1"`)
+					if r.Header.Get("If-None-Match") == `"v1"` {
+						w.WriteHeader(304)
+						return
+					}
+				case "/swr":
+					w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+					w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, n))
+				case "/vary":
+					w.Header().Set("Cache-Control", "max-age=60")
+					w.Header().Set("Vary", "Accept-Language")
+					fmt.Fprintf(w, "%s %d", r.Header.Get("Accept-Language"), n)
+					return
+				case "/private":
+					w.Header().Set("Cache-Control", "max-age=60")
+					fmt.Fprintf(w, "data for %s", r.Header.Get("Authorization"))
+					return
+				case "/expires":
+					w.Header().Set("Expires", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+				}
+				fmt.Fprintf(w, "body %d", n)
+			}))
+		})
+
+		g.BeforeEach(func() {
+			mu.Lock()
+			hits = map[string]int{}
+			conditional = map[string]int{}
+			mu.Unlock()
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should serve fresh responses from the cache", func() {
+			client := NewClient(Options{Cache: NewMemoryCache(1 << 20)})
+
+			res, str := get(client, ts.URL+"/fresh")
+			Expect(res.CacheStatus()).Should(Equal(CacheMiss))
+			Expect(str).Should(Equal("body 1"))
+
+			res, str = get(client, ts.URL+"/fresh")
+			Expect(res.CacheStatus()).Should(Equal(CacheHit))
+			Expect(str).Should(Equal("body 1"))
+			Expect(count("/fresh")).Should(Equal(1))
+		})
+
+		g.It("Should honour Expires", func() {
+			client := NewClient(Options{Cache: NewMemoryCache(1 << 20)})
+			get(client, ts.URL+"/expires")
+			res, _ := get(client, ts.URL+"/expires")
+			Expect(res.CacheStatus()).Should(Equal(CacheHit))
+		})
+
+		g.It("Should not store no-store responses", func() {
+			client := NewClient(Options{Cache: NewMemoryCache(1 << 20)})
+			get(client, ts.URL+"/nostore")
+			res, _ := get(client, ts.URL+"/nostore")
+			Expect(res.CacheStatus()).Should(Equal(CacheMiss))
+			Expect(count("/nostore")).Should(Equal(2))
+		})
+
+		g.It("Should revalidate with conditional requests", func() {
+			client := NewClient(Options{Cache: NewMemoryCache(1 << 20)})
+
+			get(client, ts.URL+"/etag")
+			res, str := get(client, ts.URL+"/etag")
+
+			Expect(res.CacheStatus()).Should(Equal(CacheRevalidated))
+			Expect(res.StatusCode).Should(Equal(200))
+			Expect(str).Should(Equal("body 1"))
+			mu.Lock()
+			Expect(conditional["/etag"]).Should(Equal(1))
+			mu.Unlock()
+		})
+
+		g.It("Should bypass fresh entries when the request sends no-cache", func() {
+			client := NewClient(Options{Cache: NewMemoryCache(1 << 20)})
+			get(client, ts.URL+"/fresh")
+			res, _ := get(client, ts.URL+"/fresh", "Cache-Control", "no-cache")
+
+			Expect(res.CacheStatus()).Should(Equal(CacheMiss))
+			Expect(count("/fresh")).Should(Equal(2))
+		})
+
+		g.It("Should serve stale responses while revalidating", func() {
+			client := NewClient(Options{Cache: NewMemoryCache(1 << 20)})
+
+			get(client, ts.URL+"/swr")
+			res, str := get(client, ts.URL+"/swr")
+			Expect(res.CacheStatus()).Should(Equal(CacheStale))
+			Expect(str).Should(Equal("body 1"))
+
+			Eventually(func() int { return count("/swr") }).Should(Equal(2))
+			Eventually(func() string {
+				_, str := get(client, ts.URL+"/swr")
+				return str
+			}).Should(Equal("body 2"))
+		})
+
+		g.It("Should key entries by the Vary headers", func() {
+			client := NewClient(Options{Cache: NewMemoryCache(1 << 20)})
+
+			_, en := get(client, ts.URL+"/vary", "Accept-Language", "en")
+			_, pt := get(client, ts.URL+"/vary", "Accept-Language", "pt")
+			Expect(en).Should(Equal("en 1"))
+			Expect(pt).Should(Equal("pt 2"))
+
+			res, pt := get(client, ts.URL+"/vary", "Accept-Language", "pt")
+			Expect(res.CacheStatus()).Should(Equal(CacheHit))
+			Expect(pt).Should(Equal("pt 2"))
+		})
+
+		g.It("Should keep the entries of different credentials apart", func() {
+			client := NewClient(Options{Cache: NewMemoryCache(1 << 20)})
+
+			_, alice := get(client, ts.URL+"/private", "Authorization", "alice")
+			res, bob := get(client, ts.URL+"/private", "Authorization", "bob")
+			Expect(alice).Should(Equal("data for alice"))
+			Expect(bob).Should(Equal("data for bob"))
+			Expect(res.CacheStatus()).Should(Equal(CacheMiss))
+
+			res, alice = get(client, ts.URL+"/private", "Authorization", "alice")
+			Expect(res.CacheStatus()).Should(Equal(CacheHit))
+			Expect(alice).Should(Equal("data for alice"))
+			_, anonymous := get(client, ts.URL+"/private")
+			Expect(anonymous).Should(Equal("data for "))
+			Expect(count("/private")).Should(Equal(3))
+		})
+
+		g.It("Should invalidate entries on unsafe methods", func() {
+			client := NewClient(Options{Cache: NewMemoryCache(1 << 20)})
+			get(client, ts.URL+"/fresh")
+			_, err := client.Do(Request{Method: "POST", Uri: ts.URL + "/fresh"})
+			Expect(err).Should(BeNil())
+
+			res, _ := get(client, ts.URL+"/fresh")
+			Expect(res.CacheStatus()).Should(Equal(CacheMiss))
+		})
+	})
+}
+
+func TestMemoryCache(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Memory cache store", func() {
+		entry := func(size int) *CachedResponse {
+			return &CachedResponse{StatusCode: 200, Header: http.Header{}, Body: []byte(strings.Repeat("x", size))}
+		}
+
+		g.It("Should evict the least recently used entries", func() {
+			cache := NewMemoryCache(100)
+			cache.Set("a", entry(40))
+			cache.Set("b", entry(40))
+			cache.Get("a")
+			cache.Set("c", entry(40))
+
+			_, ok := cache.Get("b")
+			Expect(ok).Should(BeFalse())
+			_, ok = cache.Get("a")
+			Expect(ok).Should(BeTrue())
+			_, ok = cache.Get("c")
+			Expect(ok).Should(BeTrue())
+			Expect(cache.Size()).Should(Equal(int64(80)))
+		})
+
+		g.It("Should not store entries larger than the cache", func() {
+			cache := NewMemoryCache(10)
+			cache.Set("a", entry(20))
+			Expect(cache.Len()).Should(Equal(0))
+		})
+
+		g.It("Should replace and delete entries", func() {
+			cache := NewMemoryCache(100)
+			cache.Set("a", entry(10))
+			cache.Set("a", entry(20))
+			Expect(cache.Size()).Should(Equal(int64(20)))
+
+			cache.Delete("a")
+			Expect(cache.Len()).Should(Equal(0))
+			Expect(cache.Size()).Should(Equal(int64(0)))
+		})
+	})
+}
@@ -1,6 +1,7 @@
 package goreq
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"log"
@@ -31,10 +32,82 @@ type Options struct {
 	Proxy               string
 	ProxyConnectHeaders http.Header
 	MaxIdleConnsPerHost int
+	// MaxResponseBodySize limits how many bytes can be read from a response
+	// body. Zero means no limit.
+	MaxResponseBodySize int64
+	// MaxDecompressedSize limits the size of a compressed response once
+	// decompressed. Zero means no limit.
+	MaxDecompressedSize int64
+	// MaxDecompressionRatio limits how many times a compressed response may
+	// expand. Zero means no limit.
+	MaxDecompressionRatio float64
+	// Cache enables a private HTTP cache (RFC 9111) for GET requests,
+	// backed by the given store, e.g. NewMemoryCache.
+	Cache CacheStore
+	// CoalesceRequests makes concurrent identical GET and HEAD requests
+	// share a single call. Requests are identical when they have the same
+	// method, URL, Authorization and Cookie headers and the same values for
+	// CoalesceHeaders. Shared responses are read into memory so every
+	// caller gets its own Body.
+	CoalesceRequests bool
+	CoalesceHeaders  []string
+	// RateLimit throttles requests to each host with a token bucket, unless
+	// the host has its own entry in HostRateLimits. Requests wait for a
+	// token, honouring the request context, or fail right away with a
+	// *RateLimitError when RateLimitFailFast is set. Limited hosts are also
+	// paused according to 429 responses and X-RateLimit/RateLimit headers.
+	RateLimit         RateLimit
+	HostRateLimits    map[string]RateLimit
+	RateLimitFailFast bool
+	// MaxConcurrentPerHost caps the requests in flight to each host, counting
+	// until their response body is read or closed. Extra requests wait in a
+	// queue of up to MaxQueuedPerHost requests (unbounded when zero) for at
+	// most QueueTimeout (no limit when zero) before failing with a
+	// *BulkheadError.
+	MaxConcurrentPerHost int
+	MaxQueuedPerHost     int
+	QueueTimeout         time.Duration
+	// CircuitBreaker enables a circuit breaker per host. Requests to a host
+	// whose circuit is open fail with ErrCircuitOpen.
+	CircuitBreaker *CircuitBreaker
+	// Hedge sends extra copies of slow GET, HEAD and OPTIONS requests and
+	// returns the first successful response, see Hedge.
+	Hedge *Hedge
+	// LoadBalancer spreads the requests for logical hosts, and those with a
+	// relative URI, across several endpoints, which may come from a
+	// Resolver, see LoadBalancer.
+	LoadBalancer *LoadBalancer
+	// Resolver configures the DNS server, host overrides, DNS cache and
+	// IPv4/IPv6 preference used when dialing, see DNSResolver.
+	Resolver *DNSResolver
+	// DialContext replaces the dialer of the client. The connect timeout
+	// and keep-alive still apply to the connections it makes.
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+	// UnixSocket sends every request to the Unix socket at this path,
+	// without proxy. Requests can also target a socket with "unix://" and
+	// "http+unix://" URIs.
+	UnixSocket string
+	// ProxyFunc chooses the proxy of each request, like http.Transport's
+	// Proxy, and takes precedence over Proxy. Proxies may be http, https,
+	// socks5 or socks5h URLs, with credentials in their user info.
+	ProxyFunc func(req *http.Request) (*url.URL, error)
+	// NoProxy lists the hosts reached without proxy, like NO_PROXY: "*",
+	// IP addresses, CIDR blocks and domains, which match their subdomains
+	// too, optionally with a port.
+	NoProxy []string
+	// CAFile is a PEM file with the certificate authorities to trust
+	// instead of the system ones. CertFile and KeyFile are the PEM files
+	// of the client certificate.
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// RedirectPolicy makes the client follow redirects with finer controls
+	// than MaxRedirects, which it replaces, see RedirectPolicy.
+	RedirectPolicy *RedirectPolicy
 }
 
 //AddProxyConnectHeader add an Proxy connect header.
-func (options Options) AddProxyConnectHeader(name string, value string) {
+func (options *Options) AddProxyConnectHeader(name string, value string) {
 	if options.ProxyConnectHeaders == nil {
 		options.ProxyConnectHeaders = make(http.Header)
 	}
@@ -44,6 +117,15 @@ func (options Options) AddProxyConnectHeader(name string, value string) {
 //Client for do request in http.
 type Client struct {
 	*http.Client
+	options   Options
+	roundTrip roundTripFunc
+	bulkhead  *bulkhead
+	breaker   *breaker
+	balancer  *balancer
+	dns       *dnsCache
+	err       error
+	// noTimeout is set when a zero Timeout was given on purpose.
+	noTimeout bool
 }
 
 var (
@@ -54,36 +136,75 @@ var (
 	}
 )
 
-// NewClient create a new client HTTP.
+// NewClient create a new client HTTP. Invalid options, such as a proxy URL
+// that cannot be parsed, make every request fail, see NewClientE.
 func NewClient(options Options) (client Client) {
-	mergo.Merge(&options, defaultClientOptions)
+	client, err := newClient(options)
+	if err != nil {
+		client.err = err
+	}
+	return client
+}
 
-	client = Client{newDefaultClient(options)}
+func newClient(options Options) (client Client, err error) {
+	if err := mergo.Merge(&options, defaultClientOptions); err != nil {
+		return client, &Error{Err: err}
+	}
+	return buildClient(options, nil)
+}
+
+// buildClient creates a client from options as they are, sending requests
+// with transport when it is not nil.
+func buildClient(options Options, transport http.RoundTripper) (client Client, err error) {
+	dns := newDNSCache(options.Resolver)
+	client = Client{Client: newDefaultClient(options, dns), options: options, dns: dns}
+	if transport != nil {
+		client.Transport = transport
+	}
+	client.bulkhead = newBulkhead(options)
+	client.breaker = newBreaker(options.CircuitBreaker)
+	client.balancer = newBalancer(options.LoadBalancer, client.Client)
+	client.roundTrip = client.layers(options)
+
+	if options.ProxyFunc != nil {
+		client.setProxyFunc(options.ProxyFunc, options.ProxyConnectHeaders)
+	} else if options.Proxy != "" {
+		if err := client.setProxy(options.Proxy, options.ProxyConnectHeaders); err != nil {
+			return client, err
+		}
+	}
 
-	if options.Proxy != "" {
-		client.setProxy(options.Proxy, options.ProxyConnectHeaders)
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		if errs := loadTLSFiles(options, transport.TLSClientConfig); len(errs) > 0 {
+			return client, &Error{Err: errs}
+		}
 	}
 
-	if options.MaxRedirects > 0 {
+	if options.RedirectPolicy != nil {
+		client.CheckRedirect = options.RedirectPolicy.checkRedirect()
+	} else if options.MaxRedirects > 0 {
 		client.setLimitRedirect(options.MaxRedirects)
 	}
 
-	return client
+	return client, nil
 }
 
-func newDefaultClient(options Options) *http.Client {
+func newDefaultClient(options Options, dns *dnsCache) *http.Client {
 	dialer := &net.Dialer{
 		Timeout:   defaultDialTimeout,
 		KeepAlive: defaultDialKeepAlive,
 	}
 	transport := &http.Transport{
-		DialContext: dialer.DialContext,
-		Proxy:       http.ProxyFromEnvironment,
+		DialContext: newDialContext(options, dialer, dns),
+		Proxy:       proxyFunc(options, http.ProxyFromEnvironment),
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: options.Insecure,
 		},
 		MaxIdleConnsPerHost: options.MaxIdleConnsPerHost,
 	}
+	if options.UnixSocket != "" {
+		transport.Proxy = nil
+	}
 
 	return &http.Client{
 		Transport: transport,
@@ -96,18 +217,19 @@ func newDefaultClient(options Options) *http.Client {
 }
 
 func (client Client) setProxy(proxyURL string, proxyHeaders http.Header) *Error {
-	url, err := url.Parse(proxyURL)
+	url, err := parseProxyURL(proxyURL)
 	if err != nil {
 		return &Error{Err: err}
 	}
-	proxy := http.ProxyURL(url)
+	client.setProxyFunc(http.ProxyURL(url), proxyHeaders)
+	return nil
+}
 
+func (client Client) setProxyFunc(proxy func(*http.Request) (*url.URL, error), proxyHeaders http.Header) {
 	if transport, ok := client.Transport.(*http.Transport); ok {
-		transport.Proxy = proxy
+		transport.Proxy = proxyFunc(client.options, proxy)
 		transport.ProxyConnectHeader = proxyHeaders
 	}
-
-	return nil
 }
 
 func (client Client) setLimitRedirect(maxRedirects int) {
@@ -121,7 +243,10 @@ func (client Client) setLimitRedirect(maxRedirects int) {
 }
 
 func (client Client) check() error {
-	if client.Timeout == time.Duration(0) {
+	if client.err != nil {
+		return client.err
+	}
+	if client.Timeout == time.Duration(0) && !client.noTimeout {
 		return errors.New("GoReq: Client without timeout")
 	}
 	return nil
@@ -133,7 +258,7 @@ func (client Client) setConnectTimeout(timeout time.Duration) {
 		KeepAlive: defaultDialKeepAlive,
 	}
 	if transport, ok := client.Transport.(*http.Transport); ok {
-		transport.DialContext = dialer.DialContext
+		transport.DialContext = newDialContext(client.options, dialer, client.dns)
 	}
 }
 
@@ -162,7 +287,21 @@ func (client Client) Do(request Request) (*Response, error) {
 		request.OnBeforeRequest(&request, req)
 	}
 
-	res, err := client.Client.Do(req)
+	// The transport decompresses gzip on its own, out of reach of the
+	// decompression limits, unless the request asks for an encoding.
+	compression := request.Compression
+	autoGzip := compression == nil && client.limitsDecompression() && req.Method != "HEAD" &&
+		req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == ""
+	if autoGzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	res, err := client.send(req)
+
+	limit := client.options.MaxResponseBodySize
+	if request.MaxResponseBodySize != 0 {
+		limit = request.MaxResponseBodySize
+	}
 
 	if err != nil {
 		timeout := false
@@ -178,20 +317,154 @@ func (client Client) Do(request Request) (*Response, error) {
 		var body *Body
 		var URL string
 		if res != nil {
-			body = &Body{reader: res.Body}
+			body = &Body{reader: res.Body, limit: limit}
 			URL = res.Request.URL.String()
 		}
 
 		return &Response{res, URL, body, req}, &Error{timeout: timeout, Err: err}
 	}
 
-	if request.Compression != nil && strings.Contains(res.Header.Get("Content-Encoding"), request.Compression.ContentEncoding) {
-		compressedReader, err := request.Compression.reader(res.Body)
+	if autoGzip && res.Header.Get("Content-Encoding") == "gzip" {
+		compression = Gzip()
+		res.Header.Del("Content-Encoding")
+		res.Header.Del("Content-Length")
+		res.ContentLength = -1
+		res.Uncompressed = true
+	}
+
+	if compression != nil && (res.Uncompressed || strings.Contains(res.Header.Get("Content-Encoding"), compression.ContentEncoding)) {
+		wire := &countingReader{reader: res.Body}
+		compressedReader, err := compression.reader(wire)
 		if err != nil {
 			return nil, &Error{Err: err}
 		}
-		return &Response{res, res.Request.URL.String(), &Body{reader: res.Body, compressedReader: compressedReader}, req}, nil
+		if client.limitsDecompression() {
+			compressedReader = &decompressionGuard{
+				reader:   compressedReader,
+				wire:     wire,
+				maxSize:  client.options.MaxDecompressedSize,
+				maxRatio: client.options.MaxDecompressionRatio,
+			}
+		}
+		return client.newResponse(request, res, &Body{reader: res.Body, compressedReader: compressedReader, limit: limit, wire: wire}, req)
+	}
+
+	return client.newResponse(request, res, &Body{reader: res.Body, limit: limit}, req)
+}
+
+func (client Client) limitsDecompression() bool {
+	return client.options.MaxDecompressedSize > 0 || client.options.MaxDecompressionRatio > 0
+}
+
+// layers chains the optional client features in front of the transport,
+// from the outermost to the innermost: request coalescing, caching,
+// hedging, load balancing, the circuit breaker, the per host concurrency
+// limit and rate limiting.
+func (client Client) layers(options Options) roundTripFunc {
+	next := roundTripFunc(client.do)
+
+	if limiter := newRateLimiter(options); limiter != nil {
+		next = limiter.wrap(next)
+	}
+
+	if client.bulkhead != nil {
+		next = client.bulkhead.wrap(next)
+	}
+
+	if client.breaker != nil {
+		next = client.breaker.wrap(next)
+	}
+
+	if client.balancer != nil {
+		next = client.balancer.wrap(next)
+	}
+
+	if hedger := newHedger(options.Hedge); hedger != nil {
+		next = hedger.wrap(next)
+	}
+
+	if options.Cache != nil {
+		cache, inner := newHTTPCache(options.Cache), next
+		next = func(req *http.Request) (*http.Response, error) {
+			return cache.do(req, inner)
+		}
+	}
+
+	if options.CoalesceRequests {
+		coalescer, inner := newCoalescer(options.CoalesceHeaders, options.MaxResponseBodySize), next
+		next = func(req *http.Request) (*http.Response, error) {
+			return coalescer.do(req, inner)
+		}
+	}
+
+	return next
+}
+
+// HostStats returns the in-flight and queued request counts per host when
+// Options.MaxConcurrentPerHost is set.
+func (client Client) HostStats() map[string]HostStats {
+	if client.bulkhead == nil {
+		return map[string]HostStats{}
+	}
+	return client.bulkhead.stats()
+}
+
+// CircuitStates returns the circuit breaker state of every host contacted
+// so far when Options.CircuitBreaker is set.
+func (client Client) CircuitStates() map[string]CircuitState {
+	if client.breaker == nil {
+		return map[string]CircuitState{}
+	}
+	return client.breaker.states()
+}
+
+// Endpoints returns the state of the endpoints of Options.LoadBalancer.
+func (client Client) Endpoints() []EndpointState {
+	if client.balancer == nil {
+		return []EndpointState{}
+	}
+	return client.balancer.states()
+}
+
+// Close stops the background work of the client, such as load balancer
+// health checks.
+func (client Client) Close() {
+	if client.balancer != nil {
+		client.balancer.close()
+	}
+}
+
+// send runs req through the client layers before the transport.
+func (client Client) send(req *http.Request) (*http.Response, error) {
+	if client.roundTrip != nil {
+		return client.roundTrip(req)
 	}
+	return client.do(req)
+}
+
+// noClientTimeoutKey marks the contexts of requests, such as event
+// streams, that are only bounded by their context and not by the client
+// Timeout.
+type noClientTimeoutKey struct{}
 
-	return &Response{res, res.Request.URL.String(), &Body{reader: res.Body}, req}, nil
+func (client Client) do(req *http.Request) (*http.Response, error) {
+	if req.Context().Value(noClientTimeoutKey{}) != nil && client.Timeout != 0 {
+		untimed := *client.Client
+		untimed.Timeout = 0
+		return untimed.Do(req)
+	}
+	return client.Client.Do(req)
+}
+
+func (client Client) newResponse(request Request, res *http.Response, body *Body, req *http.Request) (*Response, error) {
+	response := &Response{res, res.Request.URL.String(), body, req}
+	if request.OnDownloadProgress != nil {
+		body.progress = newProgressTracker(request.OnDownloadProgress, request.ProgressInterval, res.ContentLength)
+	}
+	if request.BufferResponse {
+		if err := body.Buffer(); err != nil {
+			return response, &Error{Err: err}
+		}
+	}
+	return response, nil
 }
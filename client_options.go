@@ -0,0 +1,144 @@
+package goreq
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ClientOption configures a client created by New.
+type ClientOption func(*clientConfig)
+
+type clientConfig struct {
+	options         Options
+	timeoutSet      bool
+	maxRedirectsSet bool
+	transport       http.RoundTripper
+}
+
+// New creates a client from functional options. Unlike NewClient, values
+// given on purpose are kept even when zero: WithTimeout(0) disables the
+// client timeout, leaving deadlines to request contexts, and
+// WithMaxRedirects(0) makes any redirect an error, while without it
+// redirect responses are returned as they are. The options are validated
+// like NewClientE does.
+func New(opts ...ClientOption) (*Client, error) {
+	config := &clientConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	options := config.options
+	if !config.timeoutSet {
+		options.Timeout = defaultClientTimeout
+	}
+
+	errs, _ := options.Validate().(OptionErrors)
+	if config.transport != nil {
+		errs = append(errs, transportConflicts(options)...)
+	}
+	if config.maxRedirectsSet && options.MaxRedirects == 0 && options.RedirectPolicy != nil {
+		errs = append(errs, &OptionError{Field: "MaxRedirects", Err: errors.New("conflicts with RedirectPolicy")})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	client, err := buildClient(options, config.transport)
+	if err != nil {
+		return nil, err
+	}
+	client.noTimeout = config.timeoutSet && options.Timeout == 0
+	if config.maxRedirectsSet && options.MaxRedirects == 0 {
+		client.setLimitRedirect(0)
+	}
+	return &client, nil
+}
+
+// transportConflicts reports the options that only apply to the transport
+// created by the client, which a custom transport replaces.
+func transportConflicts(options Options) OptionErrors {
+	var errs OptionErrors
+	conflict := func(field string, set bool) {
+		if set {
+			errs = append(errs, &OptionError{Field: field, Err: errors.New("conflicts with WithTransport")})
+		}
+	}
+	conflict("Insecure", options.Insecure)
+	conflict("Proxy", options.Proxy != "")
+	conflict("ProxyFunc", options.ProxyFunc != nil)
+	conflict("ProxyConnectHeaders", len(options.ProxyConnectHeaders) > 0)
+	conflict("NoProxy", len(options.NoProxy) > 0)
+	conflict("MaxIdleConnsPerHost", options.MaxIdleConnsPerHost != 0)
+	conflict("Resolver", options.Resolver != nil)
+	conflict("DialContext", options.DialContext != nil)
+	conflict("UnixSocket", options.UnixSocket != "")
+	conflict("CAFile", options.CAFile != "")
+	conflict("CertFile", options.CertFile != "")
+	conflict("KeyFile", options.KeyFile != "")
+	return errs
+}
+
+// WithOptions sets every field of options. Zero Timeout and MaxRedirects
+// count as unset; use WithTimeout and WithMaxRedirects for zero values.
+func WithOptions(options Options) ClientOption {
+	return func(c *clientConfig) {
+		timeout, maxRedirects := c.options.Timeout, c.options.MaxRedirects
+		c.options = options
+		if options.Timeout == 0 {
+			c.options.Timeout = timeout
+		} else {
+			c.timeoutSet = true
+		}
+		if options.MaxRedirects == 0 {
+			c.options.MaxRedirects = maxRedirects
+		} else {
+			c.maxRedirectsSet = true
+		}
+	}
+}
+
+// WithTimeout sets the timeout of the requests, none when zero.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.options.Timeout = timeout
+		c.timeoutSet = true
+	}
+}
+
+// WithMaxRedirects follows up to maxRedirects redirects, failing beyond.
+func WithMaxRedirects(maxRedirects int) ClientOption {
+	return func(c *clientConfig) {
+		c.options.MaxRedirects = maxRedirects
+		c.maxRedirectsSet = true
+	}
+}
+
+// WithTransport sends the requests with transport instead of the
+// transport created by the client.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *clientConfig) {
+		c.transport = transport
+	}
+}
+
+// WithInsecure skips the verification of server certificates.
+func WithInsecure(insecure bool) ClientOption {
+	return func(c *clientConfig) {
+		c.options.Insecure = insecure
+	}
+}
+
+// WithProxy sends the requests through the proxy at proxyURL.
+func WithProxy(proxyURL string) ClientOption {
+	return func(c *clientConfig) {
+		c.options.Proxy = proxyURL
+	}
+}
+
+// WithCookieJar stores and sends cookies with jar.
+func WithCookieJar(jar http.CookieJar) ClientOption {
+	return func(c *clientConfig) {
+		c.options.CookieJar = jar
+	}
+}
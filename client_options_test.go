@@ -0,0 +1,103 @@
+package goreq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+type countingTransport struct {
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestFunctionalOptions(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("New", func() {
+		var ts *httptest.Server
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/redirect" {
+					http.Redirect(w, r, "/", http.StatusFound)
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should use the default timeout when unset", func() {
+			client, err := New()
+			Expect(err).Should(BeNil())
+			Expect(client.Timeout).Should(Equal(defaultClientTimeout))
+		})
+
+		g.It("Should allow a zero timeout on purpose", func() {
+			client, err := New(WithTimeout(0))
+			Expect(err).Should(BeNil())
+			Expect(client.Timeout).Should(Equal(time.Duration(0)))
+
+			res, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+			Expect(res.StatusCode).Should(Equal(200))
+		})
+
+		g.It("Should tell zero redirects from unset", func() {
+			client, err := New()
+			Expect(err).Should(BeNil())
+			res, err := client.Do(Request{Uri: ts.URL + "/redirect"})
+			Expect(err).Should(BeNil())
+			Expect(res.StatusCode).Should(Equal(302))
+
+			client, err = New(WithMaxRedirects(0))
+			Expect(err).Should(BeNil())
+			_, err = client.Do(Request{Uri: ts.URL + "/redirect"})
+			Expect(err).ShouldNot(BeNil())
+
+			client, err = New(WithOptions(Options{MaxRedirects: 1}))
+			Expect(err).Should(BeNil())
+			res, err = client.Do(Request{Uri: ts.URL + "/redirect"})
+			Expect(err).Should(BeNil())
+			Expect(res.StatusCode).Should(Equal(200))
+		})
+
+		g.It("Should send requests with a custom transport", func() {
+			transport := &countingTransport{}
+			client, err := New(WithTransport(transport), WithOptions(Options{MaxConcurrentPerHost: 1}))
+			Expect(err).Should(BeNil())
+
+			res, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("ok"))
+			Expect(atomic.LoadInt32(&transport.requests)).Should(Equal(int32(1)))
+		})
+
+		g.It("Should reject options that a custom transport replaces", func() {
+			_, err := New(WithTransport(&countingTransport{}), WithInsecure(true), WithProxy("http://proxy:3128"))
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(Equal("GoReq: option Insecure: conflicts with WithTransport; GoReq: option Proxy: conflicts with WithTransport"))
+		})
+
+		g.It("Should validate the options", func() {
+			_, err := New(WithTimeout(-time.Second))
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.(OptionErrors)[0].Field).Should(Equal("Timeout"))
+		})
+	})
+}
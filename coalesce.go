@@ -0,0 +1,143 @@
+package goreq
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// coalescer shares one in-flight call between concurrent identical
+// GET and HEAD requests. Range and conditional requests are not shared.
+type coalescer struct {
+	headers []string
+	maxBody int64
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// defaultMaxCoalescedBody caps the shared body when the client sets no
+// MaxResponseBodySize.
+const defaultMaxCoalescedBody = 10 << 20
+
+type coalescedCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	res     *http.Response
+	body    []byte
+	err     error
+	// tooLarge is set when the body exceeded the cap and was not shared.
+	tooLarge bool
+}
+
+// uncoalescedHeaders make a request partial or conditional: its response
+// only fits that request and is never shared.
+var uncoalescedHeaders = []string{"Range", "If-Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"}
+
+func newCoalescer(headers []string, maxBody int64) *coalescer {
+	keyHeaders := []string{"Authorization", "Cookie", "Accept", "Accept-Encoding"}
+	for _, name := range headers {
+		keyHeaders = append(keyHeaders, http.CanonicalHeaderKey(name))
+	}
+	if maxBody <= 0 {
+		maxBody = defaultMaxCoalescedBody
+	}
+	return &coalescer{headers: keyHeaders, maxBody: maxBody, calls: map[string]*coalescedCall{}}
+}
+
+func (c *coalescer) key(req *http.Request) string {
+	var key strings.Builder
+	key.WriteString(req.Method)
+	key.WriteString(" ")
+	key.WriteString(req.URL.String())
+	for _, name := range c.headers {
+		key.WriteString("\n")
+		key.WriteString(name)
+		key.WriteString(": ")
+		key.WriteString(strings.Join(req.Header[name], ","))
+	}
+	return key.String()
+}
+
+// do waits for the shared call of req, starting it when there is none.
+// The shared call does not belong to any caller: it runs until it is done
+// or every caller stopped waiting, each on its own context.
+func (c *coalescer) do(req *http.Request, next roundTripFunc) (*http.Response, error) {
+	// Event streams never end on their own and cannot be buffered.
+	if req.Method != "GET" && req.Method != "HEAD" || req.Context().Value(noClientTimeoutKey{}) != nil {
+		return next(req)
+	}
+	for _, name := range uncoalescedHeaders {
+		if _, ok := req.Header[name]; ok {
+			return next(req)
+		}
+	}
+
+	key := c.key(req)
+	c.mu.Lock()
+	call, shared := c.calls[key]
+	if !shared {
+		ctx, cancel := context.WithCancel(context.Background())
+		call = &coalescedCall{done: make(chan struct{}), cancel: cancel}
+		c.calls[key] = call
+		go c.run(key, call, req.Clone(ctx), next)
+	}
+	call.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-req.Context().Done():
+		c.leave(key, call)
+		return nil, req.Context().Err()
+	}
+
+	if call.tooLarge {
+		return next(req)
+	}
+	if call.err != nil {
+		return nil, call.err
+	}
+	res := *call.res
+	res.Header = call.res.Header.Clone()
+	res.Body = ioutil.NopCloser(bytes.NewReader(call.body))
+	res.ContentLength = int64(len(call.body))
+	res.Request = req
+	return &res, nil
+}
+
+func (c *coalescer) run(key string, call *coalescedCall, req *http.Request, next roundTripFunc) {
+	defer call.cancel()
+	call.res, call.err = next(req)
+	if call.err == nil {
+		call.body, call.err = ioutil.ReadAll(io.LimitReader(call.res.Body, c.maxBody+1))
+		call.res.Body.Close()
+		if int64(len(call.body)) > c.maxBody {
+			call.body, call.tooLarge = nil, true
+		}
+	}
+	c.mu.Lock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// leave gives up waiting for call, cancelling it when no caller is left.
+func (c *coalescer) leave(key string, call *coalescedCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if call.waiters--; call.waiters > 0 {
+		return
+	}
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	call.cancel()
+}
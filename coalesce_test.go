@@ -0,0 +1,150 @@
+package goreq
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestCoalesceRequests(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Request coalescing", func() {
+		var ts *httptest.Server
+		var hits int32
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				time.Sleep(100 * time.Millisecond)
+				fmt.Fprintf(w, "%s %s%s", r.Method, r.Header.Get("X-Tenant"), r.Header.Get("Range"))
+			}))
+		})
+
+		g.BeforeEach(func() {
+			atomic.StoreInt32(&hits, 0)
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		run := func(client Client, n int, build func(i int) Request) []string {
+			var wg sync.WaitGroup
+			results := make([]string, n)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					res, err := client.Do(build(i))
+					Expect(err).Should(BeNil())
+					results[i], _ = res.Body.ToString()
+				}(i)
+			}
+			wg.Wait()
+			return results
+		}
+
+		g.It("Should share one call between identical concurrent GETs", func() {
+			client := NewClient(Options{CoalesceRequests: true})
+			results := run(client, 10, func(i int) Request { return Request{Uri: ts.URL} })
+
+			Expect(atomic.LoadInt32(&hits)).Should(Equal(int32(1)))
+			for _, str := range results {
+				Expect(str).Should(Equal("GET "))
+			}
+		})
+
+		g.It("Should not coalesce requests that differ in selected headers", func() {
+			client := NewClient(Options{CoalesceRequests: true, CoalesceHeaders: []string{"x-tenant"}})
+			results := run(client, 4, func(i int) Request {
+				request := Request{Uri: ts.URL}
+				request.AddHeader("X-Tenant", fmt.Sprint(i%2))
+				return request
+			})
+
+			Expect(atomic.LoadInt32(&hits)).Should(Equal(int32(2)))
+			for i, str := range results {
+				Expect(str).Should(Equal(fmt.Sprintf("GET %d", i%2)))
+			}
+		})
+
+		g.It("Should not coalesce range requests", func() {
+			client := NewClient(Options{CoalesceRequests: true})
+			results := run(client, 2, func(i int) Request {
+				request := Request{Uri: ts.URL}
+				request.AddHeader("Range", fmt.Sprintf("bytes=%d-%d", i*10, i*10+9))
+				return request
+			})
+
+			Expect(atomic.LoadInt32(&hits)).Should(Equal(int32(2)))
+			Expect(results).Should(Equal([]string{"GET bytes=0-9", "GET bytes=10-19"}))
+		})
+
+		g.It("Should keep the shared call going when the first caller cancels", func() {
+			client := NewClient(Options{CoalesceRequests: true})
+			ctx, cancel := context.WithCancel(context.Background())
+			first := make(chan error)
+			go func() {
+				_, err := client.Do(Request{Uri: ts.URL, Context: ctx})
+				first <- err
+			}()
+			Eventually(func() int32 { return atomic.LoadInt32(&hits) }).Should(Equal(int32(1)))
+
+			second := make(chan string)
+			go func() {
+				res, err := client.Do(Request{Uri: ts.URL})
+				Expect(err).Should(BeNil())
+				str, _ := res.Body.ToString()
+				second <- str
+			}()
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+
+			Expect(<-first).ShouldNot(BeNil())
+			Expect(<-second).Should(Equal("GET "))
+			Expect(atomic.LoadInt32(&hits)).Should(Equal(int32(1)))
+		})
+
+		g.It("Should not share bodies larger than MaxResponseBodySize", func() {
+			client := NewClient(Options{CoalesceRequests: true, MaxResponseBodySize: 3})
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					res, err := client.Do(Request{Uri: ts.URL})
+					Expect(err).Should(BeNil())
+					_, err = res.Body.ToString()
+					Expect(err).ShouldNot(BeNil())
+				}()
+			}
+			wg.Wait()
+
+			Expect(atomic.LoadInt32(&hits)).Should(BeNumerically(">", 3))
+		})
+
+		g.It("Should not coalesce unsafe methods", func() {
+			client := NewClient(Options{CoalesceRequests: true})
+			run(client, 3, func(i int) Request { return Request{Method: "POST", Uri: ts.URL} })
+
+			Expect(atomic.LoadInt32(&hits)).Should(Equal(int32(3)))
+		})
+
+		g.It("Should not coalesce when disabled", func() {
+			client := NewClient(Options{})
+			run(client, 3, func(i int) Request { return Request{Uri: ts.URL} })
+
+			Expect(atomic.LoadInt32(&hits)).Should(Equal(int32(3)))
+		})
+	})
+}
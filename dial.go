@@ -0,0 +1,124 @@
+package goreq
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// unixSocketHostSuffix marks the hosts standing for a Unix socket, whose
+// path is hex encoded in the rest of the host. Each socket gets its own
+// host so the transport keeps their connections apart.
+const unixSocketHostSuffix = ".unix.goreq"
+
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// newDialContext returns the DialContext of the transport: Unix sockets
+// for Options.UnixSocket and unix:// URIs, then Options.DialContext, and
+// the dialer resolving host names with dns otherwise.
+func newDialContext(options Options, dialer *net.Dialer, dns *dnsCache) dialFunc {
+	dial := dialFunc(dialContext(dialer, dns))
+	if options.DialContext != nil {
+		dial = customDialContext(options.DialContext, dialer)
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if path, ok := unixSocketPath(address); ok {
+			return dialer.DialContext(ctx, "unix", path)
+		}
+		if options.UnixSocket != "" {
+			return dialer.DialContext(ctx, "unix", options.UnixSocket)
+		}
+		return dial(ctx, network, address)
+	}
+}
+
+// customDialContext applies the timeout and keep-alive of dialer to the
+// connections made by dial.
+func customDialContext(dial dialFunc, dialer *net.Dialer) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if dialer.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, dialer.Timeout)
+			defer cancel()
+		}
+		conn, err := dial(ctx, network, address)
+		if tcp, ok := conn.(*net.TCPConn); ok && dialer.KeepAlive > 0 {
+			tcp.SetKeepAlive(true)
+			tcp.SetKeepAlivePeriod(dialer.KeepAlive)
+		}
+		return conn, err
+	}
+}
+
+// unixSocketURI rewrites "unix://" and "http+unix://" URIs into http ones
+// whose host stands for the socket. The socket path is either percent
+// encoded as the host, as in "http+unix://%2Fvar%2Frun%2Fdocker.sock/info",
+// or given as is and followed by a colon and the path of the request, as in
+// "unix:///var/run/docker.sock:/info".
+func unixSocketURI(uri string) (string, bool, error) {
+	var rest string
+	switch {
+	case strings.HasPrefix(uri, "unix://"):
+		rest = strings.TrimPrefix(uri, "unix://")
+	case strings.HasPrefix(uri, "http+unix://"):
+		rest = strings.TrimPrefix(uri, "http+unix://")
+	default:
+		return uri, false, nil
+	}
+
+	var socket, path string
+	if strings.HasPrefix(rest, "/") {
+		socket, path = rest, "/"
+		if i := strings.Index(rest, ":"); i >= 0 {
+			socket, path = rest[:i], rest[i+1:]
+		}
+	} else {
+		socket, path = rest, "/"
+		if i := strings.IndexAny(rest, "/?"); i >= 0 {
+			socket, path = rest[:i], rest[i:]
+		}
+		var err error
+		if socket, err = url.PathUnescape(socket); err != nil {
+			return "", false, err
+		}
+	}
+	if socket == "" {
+		return "", false, errors.New("GoReq: unix URI without socket path")
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return "http://" + hex.EncodeToString([]byte(socket)) + unixSocketHostSuffix + path, true, nil
+}
+
+// unixSocketPath returns the socket path of a host:port address made by
+// unixSocketURI.
+func unixSocketPath(address string) (string, bool) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	if !strings.HasSuffix(host, unixSocketHostSuffix) {
+		return "", false
+	}
+	path, err := hex.DecodeString(strings.TrimSuffix(host, unixSocketHostSuffix))
+	if err != nil {
+		return "", false
+	}
+	return string(path), true
+}
+
+// skipUnixSockets keeps requests to Unix sockets away from proxies.
+func skipUnixSockets(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if _, ok := unixSocketPath(req.URL.Host); ok {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+}
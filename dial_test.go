@@ -0,0 +1,105 @@
+package goreq
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestDial(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Unix sockets", func() {
+		var dir, socket string
+		var server *http.Server
+
+		g.Before(func() {
+			dir, _ = ioutil.TempDir("", "goreq-unix")
+			socket = filepath.Join(dir, "api.sock")
+			listener, err := net.Listen("unix", socket)
+			Expect(err).Should(BeNil())
+			server = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Host + " " + r.URL.RequestURI()))
+			})}
+			go server.Serve(listener)
+		})
+
+		g.After(func() {
+			server.Close()
+			os.RemoveAll(dir)
+		})
+
+		g.It("Should send every request to Options.UnixSocket", func() {
+			client := NewClient(Options{UnixSocket: socket})
+
+			res, err := client.Do(Request{Uri: "http://localhost/containers/json?all=1"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("localhost /containers/json?all=1"))
+		})
+
+		g.It("Should send http+unix URIs to the encoded socket", func() {
+			client := NewClient(Options{})
+
+			res, err := client.Do(Request{Uri: "http+unix://" + url.PathEscape(socket) + "/info", QueryString: url.Values{"a": {"1"}}})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("localhost /info?a=1"))
+		})
+
+		g.It("Should send unix URIs to the socket before the colon", func() {
+			client := NewClient(Options{})
+
+			res, err := client.Do(Request{Uri: "unix://" + socket + ":/version", Host: "docker"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("docker /version"))
+
+			res, err = client.Do(Request{Uri: "unix://" + socket})
+			Expect(err).Should(BeNil())
+			body, _ = res.Body.ToString()
+			Expect(body).Should(Equal("localhost /"))
+		})
+
+		g.It("Should reject unix URIs without socket", func() {
+			_, err := NewClient(Options{}).Do(Request{Uri: "http+unix://"})
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	g.Describe("Custom dialer", func() {
+		g.It("Should dial with Options.DialContext", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			}))
+			defer ts.Close()
+			target, _ := url.Parse(ts.URL)
+
+			var dials int32
+			client := NewClient(Options{DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				atomic.AddInt32(&dials, 1)
+				_, hasDeadline := ctx.Deadline()
+				Expect(hasDeadline).Should(BeTrue())
+				var d net.Dialer
+				return d.DialContext(ctx, network, target.Host)
+			}})
+
+			res, err := client.Do(Request{Uri: "http://backend.internal/"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("ok"))
+			Expect(atomic.LoadInt32(&dials)).Should(Equal(int32(1)))
+		})
+	})
+}
@@ -0,0 +1,279 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFallbackDelay = 300 * time.Millisecond
+	defaultDNSPort       = "53"
+)
+
+// IPFamily orders the IPv4 and IPv6 addresses of a host when dialing.
+type IPFamily int
+
+const (
+	// IPAny tries first the family of the first address resolved.
+	IPAny IPFamily = iota
+	// IPv4First tries IPv4 addresses first.
+	IPv4First
+	// IPv6First tries IPv6 addresses first.
+	IPv6First
+	// IPv4Only only uses IPv4 addresses.
+	IPv4Only
+	// IPv6Only only uses IPv6 addresses.
+	IPv6Only
+)
+
+// DNSResolver configures how the client resolves host names when dialing.
+//
+// Server is the address of the DNS server to ask instead of the system
+// one, with port 53 when none is given. Hosts maps "host" or "host:port"
+// keys to the IP address, or the other host name, to use instead, like
+// curl's --resolve. Lookups are cached for TTL, and hosts that do not
+// exist for NegativeTTL; zero disables each cache.
+//
+// Addresses of the preferred IPFamily are dialed first, one after the
+// other; the other family is tried in parallel after FallbackDelay (300ms
+// when unset, never in parallel when negative) or as soon as the first
+// family has failed.
+type DNSResolver struct {
+	Server        string
+	Hosts         map[string]string
+	TTL           time.Duration
+	NegativeTTL   time.Duration
+	IPFamily      IPFamily
+	FallbackDelay time.Duration
+}
+
+type dnsCache struct {
+	config       DNSResolver
+	lookupIPAddr func(ctx context.Context, host string) ([]net.IPAddr, error)
+	now          func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	ips     []net.IP
+	err     error
+	expires time.Time
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+func newDNSCache(config *DNSResolver) *dnsCache {
+	if config == nil {
+		return nil
+	}
+	c := &dnsCache{config: *config, now: time.Now, entries: map[string]dnsEntry{}}
+	if c.config.FallbackDelay == 0 {
+		c.config.FallbackDelay = defaultFallbackDelay
+	}
+
+	resolver := net.DefaultResolver
+	if server := c.config.Server; server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, defaultDNSPort)
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+	c.lookupIPAddr = resolver.LookupIPAddr
+	return c
+}
+
+// dialContext returns the DialContext of the transport, resolving host
+// names with dns when it is set.
+func dialContext(dialer *net.Dialer, dns *dnsCache) func(ctx context.Context, network, address string) (net.Conn, error) {
+	if dns == nil {
+		return dialer.DialContext
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dns.dial(ctx, dialer, network, address)
+	}
+}
+
+func (c *dnsCache) lookup(ctx context.Context, host, port string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	for _, key := range []string{net.JoinHostPort(host, port), host} {
+		if address, ok := c.config.Hosts[key]; ok {
+			if ip := net.ParseIP(address); ip != nil {
+				return []net.IP{ip}, nil
+			}
+			host = address
+			break
+		}
+	}
+
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.ips, entry.err
+	}
+
+	addrs, err := c.lookupIPAddr(ctx, host)
+	entry = dnsEntry{err: err}
+	for _, addr := range addrs {
+		entry.ips = append(entry.ips, addr.IP)
+	}
+	ttl := c.config.TTL
+	if err != nil {
+		ttl = 0
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			ttl = c.config.NegativeTTL
+		}
+	}
+	if ttl > 0 {
+		entry.expires = now.Add(ttl)
+		c.mu.Lock()
+		c.entries[host] = entry
+		c.mu.Unlock()
+	}
+	return entry.ips, entry.err
+}
+
+// order splits ips into the addresses to dial first and the fallbacks.
+func (c *dnsCache) order(network string, ips []net.IP) (primaries, fallbacks []net.IP) {
+	family := c.config.IPFamily
+	switch network {
+	case "tcp4", "udp4":
+		family = IPv4Only
+	case "tcp6", "udp6":
+		family = IPv6Only
+	}
+
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+
+	switch family {
+	case IPv4Only:
+		return v4, nil
+	case IPv6Only:
+		return v6, nil
+	case IPv4First:
+		return v4, v6
+	case IPv6First:
+		return v6, v4
+	}
+	if len(ips) > 0 && ips[0].To4() == nil {
+		return v6, v4
+	}
+	return v4, v6
+}
+
+func (c *dnsCache) dial(ctx context.Context, dialer *net.Dialer, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := c.lookup(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	primaries, fallbacks := c.order(network, ips)
+	if len(primaries) == 0 {
+		primaries, fallbacks = fallbacks, nil
+	}
+	if len(primaries) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address", Name: host}
+	}
+	if len(fallbacks) == 0 || c.config.FallbackDelay < 0 {
+		return dialSerial(ctx, dialer, network, append(primaries, fallbacks...), port)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, 2)
+	race := func(ips []net.IP) {
+		conn, err := dialSerial(ctx, dialer, network, ips, port)
+		results <- dialResult{conn: conn, err: err}
+	}
+
+	go race(primaries)
+	pending := 1
+	timer := time.NewTimer(c.config.FallbackDelay)
+	defer timer.Stop()
+	fallback := timer.C
+
+	var firstErr error
+	for {
+		select {
+		case <-fallback:
+			fallback = nil
+			pending++
+			go race(fallbacks)
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if pending > 0 {
+					go closeDials(results, pending)
+				}
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if fallback != nil {
+				fallback = nil
+				pending++
+				go race(fallbacks)
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// closeDials closes the connections of the dials that lost the race.
+func closeDials(results chan dialResult, pending int) {
+	for ; pending > 0; pending-- {
+		if r := <-results; r.conn != nil {
+			r.conn.Close()
+		}
+	}
+}
+
+func dialSerial(ctx context.Context, dialer *net.Dialer, network string, ips []net.IP, port string) (net.Conn, error) {
+	var firstErr error
+	for _, ip := range ips {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if firstErr == nil {
+		firstErr = errors.New("GoReq: no address to dial")
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,118 @@
+package goreq
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestDNSResolver(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("DNS resolver", func() {
+		var ts *httptest.Server
+		var port string
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Host))
+			}))
+			u, _ := url.Parse(ts.URL)
+			_, port, _ = net.SplitHostPort(u.Host)
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should send overridden hosts to their address", func() {
+			client := NewClient(Options{Resolver: &DNSResolver{Hosts: map[string]string{
+				"api.example.com:" + port: "127.0.0.1",
+			}}})
+
+			res, err := client.Do(Request{Uri: "http://api.example.com:" + port + "/"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("api.example.com:" + port))
+		})
+
+		g.It("Should cache lookups for the TTL", func() {
+			var lookups int32
+			dns := newDNSCache(&DNSResolver{TTL: time.Minute})
+			dns.lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+				atomic.AddInt32(&lookups, 1)
+				return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+			}
+			now := time.Now()
+			dns.now = func() time.Time { return now }
+
+			for i := 0; i < 3; i++ {
+				ips, err := dns.lookup(context.Background(), "api.example.com", port)
+				Expect(err).Should(BeNil())
+				Expect(ips[0].String()).Should(Equal("127.0.0.1"))
+			}
+			Expect(atomic.LoadInt32(&lookups)).Should(Equal(int32(1)))
+
+			now = now.Add(2 * time.Minute)
+			dns.lookup(context.Background(), "api.example.com", port)
+			Expect(atomic.LoadInt32(&lookups)).Should(Equal(int32(2)))
+		})
+
+		g.It("Should cache hosts that do not exist for the negative TTL", func() {
+			var lookups int32
+			dns := newDNSCache(&DNSResolver{NegativeTTL: time.Minute})
+			dns.lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+				atomic.AddInt32(&lookups, 1)
+				return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+			}
+
+			for i := 0; i < 3; i++ {
+				_, err := dns.lookup(context.Background(), "missing.example.com", port)
+				Expect(err).ShouldNot(BeNil())
+			}
+			Expect(atomic.LoadInt32(&lookups)).Should(Equal(int32(1)))
+		})
+
+		g.It("Should order addresses by IP family", func() {
+			ips := []net.IP{net.ParseIP("::1"), net.ParseIP("127.0.0.1")}
+
+			primaries, fallbacks := newDNSCache(&DNSResolver{}).order("tcp", ips)
+			Expect(primaries[0].String()).Should(Equal("::1"))
+			Expect(fallbacks[0].String()).Should(Equal("127.0.0.1"))
+
+			primaries, fallbacks = newDNSCache(&DNSResolver{IPFamily: IPv4First}).order("tcp", ips)
+			Expect(primaries[0].String()).Should(Equal("127.0.0.1"))
+			Expect(fallbacks[0].String()).Should(Equal("::1"))
+
+			primaries, fallbacks = newDNSCache(&DNSResolver{IPFamily: IPv4Only}).order("tcp", ips)
+			Expect(primaries).Should(HaveLen(1))
+			Expect(fallbacks).Should(BeEmpty())
+		})
+
+		g.It("Should fall back to the other family when the preferred one fails", func() {
+			client := NewClient(Options{Resolver: &DNSResolver{
+				IPFamily:      IPv6First,
+				FallbackDelay: time.Second,
+			}})
+			client.dns.lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+				return []net.IPAddr{{IP: net.ParseIP("::1")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+			}
+
+			// ts only listens on IPv4, so dialing ::1 fails right away.
+			start := time.Now()
+			res, err := client.Do(Request{Uri: "http://dual.example.com:" + port + "/"})
+			Expect(err).Should(BeNil())
+			Expect(res.StatusCode).Should(Equal(200))
+			Expect(time.Since(start)).Should(BeNumerically("<", time.Second))
+		})
+	})
+}
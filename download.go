@@ -0,0 +1,222 @@
+package goreq
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	partialSuffix   = ".part"
+	validatorSuffix = ".part.etag"
+)
+
+// Checksum describes the expected digest of a downloaded file.
+type Checksum struct {
+	New func() hash.Hash
+	Sum []byte
+}
+
+// SHA256 returns a Checksum for a hex encoded sha256 digest. Download
+// fails before sending any request when a digest is not valid hex of the
+// right length.
+func SHA256(hexSum string) Checksum {
+	return newChecksum(sha256.New, hexSum)
+}
+
+// SHA1 returns a Checksum for a hex encoded sha1 digest.
+func SHA1(hexSum string) Checksum {
+	return newChecksum(sha1.New, hexSum)
+}
+
+// MD5 returns a Checksum for a hex encoded md5 digest.
+func MD5(hexSum string) Checksum {
+	return newChecksum(md5.New, hexSum)
+}
+
+func newChecksum(h func() hash.Hash, hexSum string) Checksum {
+	sum, _ := hex.DecodeString(strings.TrimSpace(hexSum))
+	return Checksum{New: h, Sum: sum}
+}
+
+// Download fetches request into path. Data is written to path+".part" and
+// only renamed to path once complete, with its length and optional
+// checksums verified. When a partial file from a previous attempt exists,
+// the transfer is resumed with a Range request guarded by If-Range on the
+// saved strong ETag (or Last-Modified), falling back to a full download when the
+// server content changed.
+func (client Client) Download(ctx context.Context, request Request, path string, checksums ...Checksum) error {
+	for _, checksum := range checksums {
+		if err := checksum.validate(); err != nil {
+			return err
+		}
+	}
+
+	partial := path + partialSuffix
+	validatorFile := path + validatorSuffix
+
+	var offset int64
+	validator, _ := ioutil.ReadFile(validatorFile)
+	if info, err := os.Stat(partial); err == nil && len(validator) > 0 {
+		offset = info.Size()
+	}
+
+	req := request.clone(ctx)
+	if offset > 0 {
+		req.AddHeader("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.AddHeader("If-Range", string(validator))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var expected int64 = -1
+	flags := os.O_CREATE | os.O_WRONLY
+
+	switch res.StatusCode {
+	case 206:
+		start, total, ok := parseContentRange(res.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return fmt.Errorf("GoReq: unexpected Content-Range %q resuming at %d", res.Header.Get("Content-Range"), offset)
+		}
+		expected = total
+		flags |= os.O_APPEND
+	case 200:
+		offset = 0
+		if res.ContentLength >= 0 {
+			expected = res.ContentLength
+		}
+		flags |= os.O_TRUNC
+	case 416:
+		if offset == 0 {
+			return fmt.Errorf("GoReq: download failed with status %d", res.StatusCode)
+		}
+		res.Body.Close()
+		os.Remove(partial)
+		os.Remove(validatorFile)
+		return client.Download(ctx, request, path, checksums...)
+	default:
+		return fmt.Errorf("GoReq: download failed with status %d", res.StatusCode)
+	}
+	if expected < 0 && res.StatusCode == 206 && res.ContentLength >= 0 {
+		expected = offset + res.ContentLength
+	}
+
+	validator = []byte(rangeValidator(res.Header))
+	if len(validator) > 0 {
+		if err := ioutil.WriteFile(validatorFile, validator, 0644); err != nil {
+			return err
+		}
+	} else {
+		os.Remove(validatorFile)
+	}
+
+	file, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(file, res.Body)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if size := offset + written; expected >= 0 && size != expected {
+		return fmt.Errorf("GoReq: downloaded %d bytes, expected %d", size, expected)
+	}
+
+	for _, checksum := range checksums {
+		if err := verifyChecksum(partial, checksum); err != nil {
+			os.Remove(partial)
+			os.Remove(validatorFile)
+			return err
+		}
+	}
+
+	if err := os.Rename(partial, path); err != nil {
+		return err
+	}
+	os.Remove(validatorFile)
+	return nil
+}
+
+// validate reports a digest that cannot match, such as a mistyped hex
+// string, before anything is downloaded.
+func (c Checksum) validate() error {
+	if c.New == nil {
+		return fmt.Errorf("GoReq: invalid checksum: no hash function")
+	}
+	if size := c.New().Size(); len(c.Sum) != size {
+		return fmt.Errorf("GoReq: invalid checksum %x: expected a %d byte digest", c.Sum, size)
+	}
+	return nil
+}
+
+// rangeValidator returns the value to send in If-Range: the ETag when it
+// is strong, since weak ones are not allowed there, or Last-Modified.
+func rangeValidator(h http.Header) string {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return h.Get("Last-Modified")
+}
+
+func verifyChecksum(path string, checksum Checksum) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	h := checksum.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return err
+	}
+	if sum := h.Sum(nil); !bytes.Equal(sum, checksum.Sum) {
+		return fmt.Errorf("GoReq: checksum mismatch, got %x expected %x", sum, checksum.Sum)
+	}
+	return nil
+}
+
+// parseContentRange parses "bytes start-end/total", returning -1 as total
+// when it is unknown.
+func parseContentRange(header string) (start, total int64, ok bool) {
+	if !strings.HasPrefix(header, "bytes ") {
+		return 0, 0, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(header, "bytes "), "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	bounds := strings.SplitN(parts[0], "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	total = -1
+	if parts[1] != "*" {
+		if total, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, total, true
+}
@@ -0,0 +1,136 @@
+package goreq
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestDownload(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Download", func() {
+		var ts *httptest.Server
+		var dir string
+		var lastRange string
+		content := []byte(strings.Repeat("0123456789", 1000))
+		sum := sha256.Sum256(content)
+		modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		g.Before(func() {
+			dir, _ = ioutil.TempDir("", "goreq")
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				lastRange = r.Header.Get("Range")
+				if r.URL.Path == "/weak" {
+					w.Header().Set("ETag", `W/"v1"`)
+					if lastRange == "" {
+						// Cut the first transfer short.
+						w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+						w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+						w.Write(content[:4000])
+						return
+					}
+					http.ServeContent(w, r, "file", modTime, bytes.NewReader(content))
+					return
+				}
+				w.Header().Set("ETag", `"v1"`)
+				http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+			os.RemoveAll(dir)
+		})
+
+		g.It("Should download into the given path", func() {
+			path := filepath.Join(dir, "full")
+			err := NewClient(Options{}).Download(context.Background(), Request{Uri: ts.URL}, path, SHA256(hex.EncodeToString(sum[:])))
+			Expect(err).Should(BeNil())
+
+			b, _ := ioutil.ReadFile(path)
+			Expect(b).Should(Equal(content))
+			_, err = os.Stat(path + ".part")
+			Expect(os.IsNotExist(err)).Should(BeTrue())
+		})
+
+		g.It("Should resume a partial download", func() {
+			path := filepath.Join(dir, "resume")
+			ioutil.WriteFile(path+".part", content[:4000], 0644)
+			ioutil.WriteFile(path+".part.etag", []byte(`"v1"`), 0644)
+
+			err := NewClient(Options{}).Download(context.Background(), Request{Uri: ts.URL}, path)
+			Expect(err).Should(BeNil())
+			Expect(lastRange).Should(Equal("bytes=4000-"))
+
+			b, _ := ioutil.ReadFile(path)
+			Expect(b).Should(Equal(content))
+			_, err = os.Stat(path + ".part.etag")
+			Expect(os.IsNotExist(err)).Should(BeTrue())
+		})
+
+		g.It("Should resume with Last-Modified when the ETag is weak", func() {
+			path := filepath.Join(dir, "weak")
+			err := NewClient(Options{}).Download(context.Background(), Request{Uri: ts.URL + "/weak"}, path)
+			Expect(err).ShouldNot(BeNil())
+			validator, _ := ioutil.ReadFile(path + ".part.etag")
+			Expect(string(validator)).Should(Equal(modTime.Format(http.TimeFormat)))
+
+			err = NewClient(Options{}).Download(context.Background(), Request{Uri: ts.URL + "/weak"}, path)
+			Expect(err).Should(BeNil())
+			Expect(lastRange).Should(Equal("bytes=4000-"))
+			b, _ := ioutil.ReadFile(path)
+			Expect(b).Should(Equal(content))
+		})
+
+		g.It("Should restart when the ETag changed", func() {
+			path := filepath.Join(dir, "changed")
+			ioutil.WriteFile(path+".part", []byte("stale"), 0644)
+			ioutil.WriteFile(path+".part.etag", []byte(`"v0"`), 0644)
+
+			err := NewClient(Options{}).Download(context.Background(), Request{Uri: ts.URL}, path)
+			Expect(err).Should(BeNil())
+
+			b, _ := ioutil.ReadFile(path)
+			Expect(b).Should(Equal(content))
+		})
+
+		g.It("Should fail and discard the file on checksum mismatch", func() {
+			path := filepath.Join(dir, "mismatch")
+			err := NewClient(Options{}).Download(context.Background(), Request{Uri: ts.URL}, path, SHA256(strings.Repeat("00", 32)))
+			Expect(err).ShouldNot(BeNil())
+
+			_, err = os.Stat(path)
+			Expect(os.IsNotExist(err)).Should(BeTrue())
+			_, err = os.Stat(path + ".part")
+			Expect(os.IsNotExist(err)).Should(BeTrue())
+		})
+
+		g.It("Should reject an invalid checksum before downloading", func() {
+			path := filepath.Join(dir, "invalid")
+			lastRange = "untouched"
+			for _, checksum := range []Checksum{SHA256("not hex"), SHA256(strings.Repeat("0", 63)), SHA1(hex.EncodeToString(sum[:]))} {
+				err := NewClient(Options{}).Download(context.Background(), Request{Uri: ts.URL}, path, checksum)
+				Expect(err).ShouldNot(BeNil())
+				Expect(err.Error()).Should(ContainSubstring("invalid checksum"))
+			}
+			Expect(lastRange).Should(Equal("untouched"))
+			_, err := os.Stat(path + ".part")
+			Expect(os.IsNotExist(err)).Should(BeTrue())
+		})
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"compress/zlib"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 	"net/url"
 	"reflect"
 	"strings"
+	"time"
 )
 
 type itimeout interface {
@@ -37,6 +39,19 @@ type Request struct {
 	BasicAuthPassword string
 	ShowDebug         bool
 	OnBeforeRequest   func(goreq *Request, httpreq *http.Request)
+	Context           context.Context
+	// MaxResponseBodySize overrides Options.MaxResponseBodySize for this
+	// request. A negative value disables the limit.
+	MaxResponseBodySize int64
+	// BufferResponse reads the whole response body before Client.Do
+	// returns, see Body.Buffer.
+	BufferResponse bool
+	// OnUploadProgress and OnDownloadProgress are called at most once per
+	// ProgressInterval (100ms by default) while the request and response
+	// bodies are transferred, and once more when they are complete.
+	OnUploadProgress   func(Progress)
+	OnDownloadProgress func(Progress)
+	ProgressInterval   time.Duration
 }
 
 type compression struct {
@@ -61,8 +76,23 @@ type headerTuple struct {
 type Body struct {
 	reader           io.ReadCloser
 	compressedReader io.ReadCloser
+	limit            int64
+	read             int64
+	buffer           []byte
+	buffered         *bytes.Reader
+	wire             *countingReader
+	progress         *progressTracker
+	logical          int64
 }
 
+// ErrBodyTooLarge is returned when reading a response body beyond its
+// configured size limit.
+var ErrBodyTooLarge = errors.New("GoReq: response body too large")
+
+// maxDiscardSize is how much Body.Discard reads before giving up on
+// reusing the connection.
+const maxDiscardSize = 256 << 10
+
 type Error struct {
 	timeout bool
 	Err     error
@@ -72,18 +102,67 @@ func (e *Error) Timeout() bool {
 	return e.timeout
 }
 
+// CircuitOpen reports whether the request was rejected by an open circuit breaker.
+func (e *Error) CircuitOpen() bool {
+	return e.Err == ErrCircuitOpen
+}
+
 func (e *Error) Error() string {
 	return e.Err.Error()
 }
 
 func (b *Body) Read(p []byte) (int, error) {
+	if b.limit <= 0 || b.buffered != nil {
+		return b.readRaw(p)
+	}
+	if remaining := b.limit - b.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.readRaw(p)
+	if b.read+int64(n) > b.limit {
+		n = int(b.limit - b.read)
+		b.read = b.limit
+		return n, ErrBodyTooLarge
+	}
+	b.read += int64(n)
+	return n, err
+}
+
+func (b *Body) readRaw(p []byte) (int, error) {
+	if b.buffered != nil {
+		return b.buffered.Read(p)
+	}
+	var n int
+	var err error
 	if b.compressedReader != nil {
-		return b.compressedReader.Read(p)
+		n, err = b.compressedReader.Read(p)
+	} else {
+		n, err = b.reader.Read(p)
+	}
+	if b.progress != nil {
+		b.reportProgress(n, err)
+	}
+	return n, err
+}
+
+func (b *Body) reportProgress(n int, err error) {
+	b.logical += int64(n)
+	wire := b.logical
+	if b.wire != nil {
+		wire = b.wire.n
+	}
+	if err == io.EOF {
+		b.progress.report(b.logical, wire, true)
+		b.progress = nil
+	} else if n > 0 {
+		b.progress.report(b.logical, wire, false)
 	}
-	return b.reader.Read(p)
 }
 
 func (b *Body) Close() error {
+	if b.buffered != nil {
+		return nil
+	}
 	err := b.reader.Close()
 	if b.compressedReader != nil {
 		return b.compressedReader.Close()
@@ -92,10 +171,16 @@ func (b *Body) Close() error {
 }
 
 func (b *Body) FromJsonTo(o interface{}) error {
+	if b.buffered != nil {
+		return json.Unmarshal(b.buffer, o)
+	}
 	return json.NewDecoder(b).Decode(o)
 }
 
 func (b *Body) ToString() (string, error) {
+	if b.buffered != nil {
+		return string(b.buffer), nil
+	}
 	body, err := ioutil.ReadAll(b)
 	if err != nil {
 		return "", err
@@ -103,6 +188,115 @@ func (b *Body) ToString() (string, error) {
 	return string(body), nil
 }
 
+// Bytes reads the whole body, up to its size limit. For buffered bodies it
+// returns a copy, which the caller may modify.
+func (b *Body) Bytes() ([]byte, error) {
+	if b.buffered != nil {
+		return append([]byte(nil), b.buffer...), nil
+	}
+	return ioutil.ReadAll(b)
+}
+
+// Buffer reads the whole body into memory, up to its size limit, and closes
+// the underlying connection body. Afterwards ToString, FromJsonTo, Bytes and
+// Reader can be called any number of times.
+func (b *Body) Buffer() error {
+	if b.buffered != nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(b)
+	b.Close()
+	if err != nil {
+		return err
+	}
+	b.buffer = body
+	b.buffered = bytes.NewReader(body)
+	return nil
+}
+
+// Reader returns a reader over the body. For buffered bodies each call
+// returns a new reader starting at the beginning.
+func (b *Body) Reader() io.Reader {
+	if b.buffered != nil {
+		return bytes.NewReader(b.buffer)
+	}
+	return b
+}
+
+// ToStringLimit reads at most n bytes of the body, returning ErrBodyTooLarge
+// if there is more to read.
+func (b *Body) ToStringLimit(n int64) (string, error) {
+	if b.buffered != nil {
+		if int64(len(b.buffer)) > n {
+			return string(b.buffer[:n]), ErrBodyTooLarge
+		}
+		return string(b.buffer), nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(b, n+1))
+	if int64(len(body)) > n {
+		return string(body[:n]), ErrBodyTooLarge
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// Discard drains what is left of the body, up to a fixed limit, and closes
+// it so the underlying connection can be reused.
+func (b *Body) Discard() error {
+	if b.buffered != nil {
+		return nil
+	}
+	io.CopyN(ioutil.Discard, b.reader, maxDiscardSize)
+	return b.Close()
+}
+
+// ErrDecompressionLimit is returned when a compressed response expands
+// beyond Options.MaxDecompressedSize or Options.MaxDecompressionRatio.
+var ErrDecompressionLimit = errors.New("GoReq: decompressed response exceeds the configured limit")
+
+// minRatioCheckSize is how many decompressed bytes are read before the
+// expansion ratio is enforced, so small payloads are not rejected.
+const minRatioCheckSize = 1 << 20
+
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type decompressionGuard struct {
+	reader   io.ReadCloser
+	wire     *countingReader
+	maxSize  int64
+	maxRatio float64
+	n        int64
+}
+
+func (d *decompressionGuard) Read(p []byte) (int, error) {
+	n, err := d.reader.Read(p)
+	d.n += int64(n)
+	if d.maxSize > 0 && d.n > d.maxSize {
+		n -= int(d.n - d.maxSize)
+		d.n = d.maxSize
+		return n, ErrDecompressionLimit
+	}
+	if d.maxRatio > 0 && d.n > minRatioCheckSize && d.wire.n > 0 && float64(d.n)/float64(d.wire.n) > d.maxRatio {
+		return n, ErrDecompressionLimit
+	}
+	return n, err
+}
+
+func (d *decompressionGuard) Close() error {
+	return d.reader.Close()
+}
+
 func Gzip() *compression {
 	reader := func(buffer io.Reader) (io.ReadCloser, error) {
 		return gzip.NewReader(buffer)
@@ -176,6 +370,9 @@ func paramParseStruct(v *url.Values, query interface{}) error {
 		squash = opts.Contains("squash")
 
 		if squash {
+			if (field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface) && field.IsNil() {
+				continue
+			}
 			err := paramParseStruct(v, field.Interface())
 			if err != nil {
 				return err
@@ -187,13 +384,32 @@ func paramParseStruct(v *url.Values, query interface{}) error {
 			name = strings.ToLower(typeField.Name)
 		}
 
-		if val := fmt.Sprintf("%v", field.Interface()); !(omitEmpty && len(val) == 0) {
-			v.Add(name, val)
-		}
+		paramAddField(v, name, field, omitEmpty)
 	}
 	return nil
 }
 
+// paramAddField adds the value of field under name the way DecodeQuery
+// reads it back: pointers are dereferenced, nil ones skipped, and slices
+// add one value per element.
+func paramAddField(v *url.Values, name string, field reflect.Value, omitEmpty bool) {
+	for field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return
+		}
+		field = field.Elem()
+	}
+	if field.Kind() == reflect.Slice || field.Kind() == reflect.Array {
+		for i := 0; i < field.Len(); i++ {
+			paramAddField(v, name, field.Index(i), omitEmpty)
+		}
+		return
+	}
+	if val := fmt.Sprintf("%v", field.Interface()); !(omitEmpty && len(val) == 0) {
+		v.Add(name, val)
+	}
+}
+
 func prepareRequestBody(b interface{}) (io.Reader, error) {
 	switch b.(type) {
 	case string:
@@ -225,6 +441,14 @@ func (request *Request) AddHeader(name string, value string) {
 	request.headers = append(request.headers, headerTuple{name: name, value: value})
 }
 
+// clone returns a copy of the request bound to ctx whose headers can be
+// added to without affecting the original.
+func (request Request) clone(ctx context.Context) Request {
+	request.Context = ctx
+	request.headers = append([]headerTuple(nil), request.headers...)
+	return request
+}
+
 //AddCookie add cookie in request.
 func (request *Request) AddCookie(cookie *http.Cookie) {
 	request.cookies = append(request.cookies, cookie)
@@ -261,6 +485,7 @@ func (r Request) NewRequest() (*http.Request, error) {
 	}
 
 	var bodyReader io.Reader
+	var logicalSize int64 = -1
 	if b != nil && r.Compression != nil {
 		buffer := bytes.NewBuffer([]byte{})
 		readBuffer := bufio.NewReader(b)
@@ -268,7 +493,7 @@ func (r Request) NewRequest() (*http.Request, error) {
 		if err != nil {
 			return nil, &Error{Err: err}
 		}
-		_, e = readBuffer.WriteTo(writer)
+		logicalSize, e = readBuffer.WriteTo(writer)
 		writer.Close()
 		if e != nil {
 			return nil, &Error{Err: e}
@@ -278,12 +503,33 @@ func (r Request) NewRequest() (*http.Request, error) {
 		bodyReader = b
 	}
 
-	req, err := http.NewRequest(r.Method, r.Uri, bodyReader)
+	uri, unixSocket, err := unixSocketURI(r.Uri)
+	if err != nil {
+		return nil, &Error{Err: err}
+	}
+	req, err := http.NewRequest(r.Method, uri, bodyReader)
 	if err != nil {
 		return nil, err
 	}
+	if r.Context != nil {
+		req = req.WithContext(r.Context)
+	}
+	if r.OnUploadProgress != nil && req.Body != nil && req.Body != http.NoBody {
+		total := req.ContentLength
+		if total == 0 {
+			total = -1
+		}
+		req.Body = &uploadProgress{
+			reader:       req.Body,
+			tracker:      newProgressTracker(r.OnUploadProgress, r.ProgressInterval, total),
+			logicalTotal: logicalSize,
+		}
+	}
 	// add headers to the request
 	req.Host = r.Host
+	if unixSocket && req.Host == "" {
+		req.Host = "localhost"
+	}
 
 	r.addHeaders(req.Header)
 	if r.Compression != nil {
@@ -0,0 +1,238 @@
+package goreq
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHedgeDelay   = 100 * time.Millisecond
+	hedgeSamples        = 100
+	minHedgeSamples     = 10
+	maxHedgeBudgetSaved = 10
+)
+
+// Hedge configures hedged requests for GET, HEAD and OPTIONS requests
+// without a body. When the response has not arrived after the hedge delay,
+// another copy of the request is sent, up to MaxHedges extra copies (1
+// when unset). The first successful response wins: the other copies are
+// cancelled and their bodies closed.
+//
+// The delay is the Percentile (e.g. 0.95) of the latencies observed by the
+// client once enough requests were made, and Delay (100ms when unset)
+// otherwise. Budget caps
+// the extra load: every request earns Budget hedges (e.g. 0.1 for at most
+// 10% more requests), up to 10 saved. A zero Budget means no cap.
+type Hedge struct {
+	Delay      time.Duration
+	Percentile float64
+	MaxHedges  int
+	Budget     float64
+}
+
+type hedger struct {
+	config Hedge
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+	tokens    float64
+}
+
+type hedgeResult struct {
+	index int
+	res   *http.Response
+	err   error
+}
+
+func newHedger(config *Hedge) *hedger {
+	if config == nil {
+		return nil
+	}
+	c := *config
+	if c.Delay <= 0 {
+		c.Delay = defaultHedgeDelay
+	}
+	if c.MaxHedges <= 0 {
+		c.MaxHedges = 1
+	}
+	return &hedger{config: c}
+}
+
+func hedgeable(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return req.Body == nil || req.Body == http.NoBody
+	}
+	return false
+}
+
+func (h *hedger) wrap(next roundTripFunc) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		if !hedgeable(req) {
+			return next(req)
+		}
+		return h.do(req, next)
+	}
+}
+
+// do sends copies of req until one succeeds. Every copy is a clone, since
+// the layers below and the cookie jar write to the request headers.
+func (h *hedger) do(req *http.Request, next roundTripFunc) (*http.Response, error) {
+	h.earn()
+	start := time.Now()
+	results := make(chan hedgeResult, h.config.MaxHedges+1)
+	var cancels []context.CancelFunc
+
+	launch := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			res, err := next(req.Clone(ctx))
+			results <- hedgeResult{index: index, res: res, err: err}
+		}()
+	}
+
+	launch()
+	pending := 1
+	timer := time.NewTimer(h.delay())
+	defer timer.Stop()
+	hedges := timer.C
+
+	var failed *hedgeResult
+	for {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil && r.res.StatusCode < 500 {
+				h.observe(time.Since(start))
+				discardHedges(cancels, r.index, results, pending, failed)
+				if r.res.Body != nil {
+					r.res.Body = &releaseBody{reader: r.res.Body, release: cancels[r.index]}
+				}
+				return r.res, nil
+			}
+			if failed != nil {
+				discardHedge(*failed, cancels[failed.index])
+			}
+			failed = &r
+			if pending > 0 {
+				continue
+			}
+			if failed.err != nil {
+				cancels[failed.index]()
+				return nil, failed.err
+			}
+			if failed.res.Body != nil {
+				failed.res.Body = &releaseBody{reader: failed.res.Body, release: cancels[failed.index]}
+			}
+			return failed.res, nil
+		case <-hedges:
+			if !h.spend() {
+				hedges = nil
+				continue
+			}
+			launch()
+			pending++
+			if len(cancels) > h.config.MaxHedges {
+				hedges = nil
+			} else {
+				timer.Reset(h.delay())
+			}
+		case <-req.Context().Done():
+			discardHedges(cancels, -1, results, pending, failed)
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// discardHedges cancels every copy but the winner and closes the bodies of
+// the ones still in flight once they return.
+func discardHedges(cancels []context.CancelFunc, winner int, results chan hedgeResult, pending int, failed *hedgeResult) {
+	if failed != nil {
+		discardHedge(*failed, cancels[failed.index])
+	}
+	for i, cancel := range cancels {
+		if i != winner {
+			cancel()
+		}
+	}
+	if pending == 0 {
+		return
+	}
+	go func() {
+		for ; pending > 0; pending-- {
+			r := <-results
+			discardHedge(r, cancels[r.index])
+		}
+	}()
+}
+
+func discardHedge(r hedgeResult, cancel context.CancelFunc) {
+	if r.res != nil && r.res.Body != nil {
+		r.res.Body.Close()
+	}
+	cancel()
+}
+
+// delay returns how long to wait before sending the next copy.
+func (h *hedger) delay() time.Duration {
+	if h.config.Percentile <= 0 {
+		return h.config.Delay
+	}
+	h.mu.Lock()
+	if len(h.latencies) < minHedgeSamples {
+		h.mu.Unlock()
+		return h.config.Delay
+	}
+	latencies := append([]time.Duration(nil), h.latencies...)
+	h.mu.Unlock()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	index := int(math.Ceil(h.config.Percentile*float64(len(latencies)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(latencies) {
+		index = len(latencies) - 1
+	}
+	return latencies[index]
+}
+
+func (h *hedger) observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < hedgeSamples {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgeSamples
+}
+
+func (h *hedger) earn() {
+	if h.config.Budget <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens = math.Min(maxHedgeBudgetSaved, h.tokens+h.config.Budget)
+}
+
+func (h *hedger) spend() bool {
+	if h.config.Budget <= 0 {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tokens < 1 {
+		return false
+	}
+	h.tokens--
+	return true
+}
@@ -0,0 +1,147 @@
+package goreq
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestHedge(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Hedged requests", func() {
+		var ts *httptest.Server
+		var hits int32
+		var cancelled int32
+		var slow int32
+		var cookies []string
+		var cookiesMu sync.Mutex
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&hits, 1)
+				cookiesMu.Lock()
+				cookies = append(cookies, strings.Join(r.Header["Cookie"], "; "))
+				cookiesMu.Unlock()
+				if n <= atomic.LoadInt32(&slow) {
+					select {
+					case <-r.Context().Done():
+						atomic.AddInt32(&cancelled, 1)
+						return
+					case <-time.After(2 * time.Second):
+					}
+				}
+				w.WriteHeader(200)
+				w.Write([]byte(r.Method))
+			}))
+		})
+
+		g.BeforeEach(func() {
+			atomic.StoreInt32(&hits, 0)
+			atomic.StoreInt32(&cancelled, 0)
+			atomic.StoreInt32(&slow, 1)
+			cookiesMu.Lock()
+			cookies = nil
+			cookiesMu.Unlock()
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should return the first response and cancel the slow copy", func() {
+			client := NewClient(Options{Hedge: &Hedge{Delay: 20 * time.Millisecond}})
+			start := time.Now()
+			res, err := client.Do(Request{Uri: ts.URL})
+
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("GET"))
+			Expect(time.Since(start)).Should(BeNumerically("<", time.Second))
+			Expect(atomic.LoadInt32(&hits)).Should(Equal(int32(2)))
+			Eventually(func() int32 { return atomic.LoadInt32(&cancelled) }).Should(Equal(int32(1)))
+		})
+
+		g.It("Should send up to MaxHedges extra copies", func() {
+			atomic.StoreInt32(&slow, 2)
+			client := NewClient(Options{Hedge: &Hedge{Delay: 20 * time.Millisecond, MaxHedges: 2}})
+			res, err := client.Do(Request{Uri: ts.URL})
+
+			Expect(err).Should(BeNil())
+			Expect(res.StatusCode).Should(Equal(200))
+			Expect(atomic.LoadInt32(&hits)).Should(Equal(int32(3)))
+			Eventually(func() int32 { return atomic.LoadInt32(&cancelled) }).Should(Equal(int32(2)))
+		})
+
+		g.It("Should send each copy with its own headers", func() {
+			atomic.StoreInt32(&slow, 2)
+			jar, _ := cookiejar.New(nil)
+			u, _ := url.Parse(ts.URL)
+			jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc"}})
+			client := NewClient(Options{CookieJar: jar, Hedge: &Hedge{Delay: 20 * time.Millisecond, MaxHedges: 2}})
+
+			request := Request{Uri: ts.URL}
+			request.AddHeader("X-Trace", "1")
+			res, err := client.Do(request)
+			Expect(err).Should(BeNil())
+			res.Body.Close()
+
+			cookiesMu.Lock()
+			defer cookiesMu.Unlock()
+			Expect(cookies).Should(Equal([]string{"session=abc", "session=abc", "session=abc"}))
+		})
+
+		g.It("Should not hedge requests that are not idempotent reads", func() {
+			atomic.StoreInt32(&slow, 0)
+			client := NewClient(Options{Hedge: &Hedge{}})
+			res, err := client.Do(Request{Method: "POST", Uri: ts.URL, Body: "data"})
+
+			Expect(err).Should(BeNil())
+			Expect(res.StatusCode).Should(Equal(200))
+			Expect(atomic.LoadInt32(&hits)).Should(Equal(int32(1)))
+		})
+
+		g.It("Should stop hedging when the budget is spent", func() {
+			atomic.StoreInt32(&slow, 0)
+			client := NewClient(Options{Hedge: &Hedge{Budget: 0.5}})
+
+			for i := 0; i < 4; i++ {
+				res, err := client.Do(Request{Uri: ts.URL})
+				Expect(err).Should(BeNil())
+				res.Body.Close()
+			}
+			Expect(atomic.LoadInt32(&hits)).Should(BeNumerically("<=", 6))
+		})
+
+		g.It("Should wait the default delay before hedging", func() {
+			atomic.StoreInt32(&slow, 0)
+			client := NewClient(Options{Hedge: &Hedge{Percentile: 0.95}})
+			res, err := client.Do(Request{Uri: ts.URL})
+
+			Expect(err).Should(BeNil())
+			res.Body.Close()
+			Expect(atomic.LoadInt32(&hits)).Should(Equal(int32(1)))
+			Expect(newHedger(&Hedge{}).delay()).Should(Equal(defaultHedgeDelay))
+		})
+
+		g.It("Should use the latency percentile as delay once there are enough samples", func() {
+			h := newHedger(&Hedge{Delay: time.Second, Percentile: 0.9})
+			Expect(h.delay()).Should(Equal(time.Second))
+
+			for i := 1; i <= 10; i++ {
+				h.observe(time.Duration(i) * time.Millisecond)
+			}
+			Expect(h.delay()).Should(Equal(9 * time.Millisecond))
+		})
+	})
+}
@@ -0,0 +1,253 @@
+package goreq
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// OptionError reports an invalid client setting.
+type OptionError struct {
+	Field string
+	Err   error
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("GoReq: option %s: %v", e.Field, e.Err)
+}
+
+// OptionErrors collects every OptionError found while validating Options.
+type OptionErrors []*OptionError
+
+func (e OptionErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// NewClientE validates options and returns a client, or an OptionErrors
+// listing every problem found. Unlike NewClient, nothing is ignored.
+func NewClientE(options Options) (*Client, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	client, err := newClient(options)
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// Validate checks options for invalid values, files that cannot be loaded
+// and settings that conflict, returning an OptionErrors when there are.
+func (options Options) Validate() error {
+	v := &optionsValidator{}
+
+	v.notNegative("Timeout", int64(options.Timeout))
+	v.notNegative("MaxRedirects", int64(options.MaxRedirects))
+	v.notNegative("MaxIdleConnsPerHost", int64(options.MaxIdleConnsPerHost))
+	v.notNegative("MaxResponseBodySize", options.MaxResponseBodySize)
+	v.notNegative("MaxDecompressedSize", options.MaxDecompressedSize)
+	if options.MaxDecompressionRatio != 0 && options.MaxDecompressionRatio < 1 {
+		v.add("MaxDecompressionRatio", "must be at least 1")
+	}
+	if len(options.CoalesceHeaders) > 0 && !options.CoalesceRequests {
+		v.add("CoalesceHeaders", "requires CoalesceRequests")
+	}
+
+	v.rateLimit("RateLimit", options.RateLimit)
+	for _, host := range sortedKeys(options.HostRateLimits) {
+		v.rateLimit(fmt.Sprintf("HostRateLimits[%s]", host), options.HostRateLimits[host])
+	}
+	if options.RateLimitFailFast && options.RateLimit.Rate == 0 && len(options.HostRateLimits) == 0 {
+		v.add("RateLimitFailFast", "requires RateLimit or HostRateLimits")
+	}
+
+	v.notNegative("MaxConcurrentPerHost", int64(options.MaxConcurrentPerHost))
+	v.notNegative("MaxQueuedPerHost", int64(options.MaxQueuedPerHost))
+	v.notNegative("QueueTimeout", int64(options.QueueTimeout))
+	if options.MaxConcurrentPerHost == 0 {
+		if options.MaxQueuedPerHost != 0 {
+			v.add("MaxQueuedPerHost", "requires MaxConcurrentPerHost")
+		}
+		if options.QueueTimeout != 0 {
+			v.add("QueueTimeout", "requires MaxConcurrentPerHost")
+		}
+	}
+
+	if cb := options.CircuitBreaker; cb != nil {
+		if cb.FailureRate < 0 || cb.FailureRate > 1 {
+			v.add("CircuitBreaker.FailureRate", "must be between 0 and 1")
+		}
+		v.notNegative("CircuitBreaker.MinRequests", int64(cb.MinRequests))
+		v.notNegative("CircuitBreaker.Window", int64(cb.Window))
+		v.notNegative("CircuitBreaker.CoolDown", int64(cb.CoolDown))
+		v.notNegative("CircuitBreaker.HalfOpenProbes", int64(cb.HalfOpenProbes))
+	}
+
+	if h := options.Hedge; h != nil {
+		v.notNegative("Hedge.Delay", int64(h.Delay))
+		if h.Percentile < 0 || h.Percentile >= 1 {
+			v.add("Hedge.Percentile", "must be between 0 and 1")
+		}
+		v.notNegative("Hedge.MaxHedges", int64(h.MaxHedges))
+		if h.Budget < 0 {
+			v.add("Hedge.Budget", "must not be negative")
+		}
+	}
+
+	if lb := options.LoadBalancer; lb != nil {
+		if len(lb.Endpoints) == 0 && lb.Resolver == nil {
+			v.add("LoadBalancer.Endpoints", "requires at least one endpoint or a Resolver")
+		}
+		for i, e := range lb.Endpoints {
+			if _, err := parseEndpoint(e.URL); err != nil {
+				v.addErr(fmt.Sprintf("LoadBalancer.Endpoints[%d].URL", i), err)
+			}
+			v.notNegative(fmt.Sprintf("LoadBalancer.Endpoints[%d].Weight", i), int64(e.Weight))
+		}
+		if lb.Policy < RoundRobin || lb.Policy > ConsistentHash {
+			v.add("LoadBalancer.Policy", "unknown policy")
+		}
+		v.notNegative("LoadBalancer.MaxFailures", int64(lb.MaxFailures))
+		v.notNegative("LoadBalancer.EjectionTime", int64(lb.EjectionTime))
+		v.notNegative("LoadBalancer.RefreshInterval", int64(lb.RefreshInterval))
+		if hc := lb.HealthCheck; hc != nil {
+			v.notNegative("LoadBalancer.HealthCheck.Interval", int64(hc.Interval))
+			v.notNegative("LoadBalancer.HealthCheck.Timeout", int64(hc.Timeout))
+		}
+	}
+
+	if r := options.Resolver; r != nil {
+		v.notNegative("Resolver.TTL", int64(r.TTL))
+		v.notNegative("Resolver.NegativeTTL", int64(r.NegativeTTL))
+		if r.IPFamily < IPAny || r.IPFamily > IPv6Only {
+			v.add("Resolver.IPFamily", "unknown IP family")
+		}
+		for _, host := range sortedKeys(r.Hosts) {
+			if r.Hosts[host] == "" {
+				v.add(fmt.Sprintf("Resolver.Hosts[%s]", host), "empty address")
+			}
+		}
+		if options.DialContext != nil {
+			v.add("Resolver", "conflicts with DialContext")
+		}
+		if options.UnixSocket != "" {
+			v.add("Resolver", "conflicts with UnixSocket")
+		}
+	}
+	if options.DialContext != nil && options.UnixSocket != "" {
+		v.add("DialContext", "conflicts with UnixSocket")
+	}
+
+	if options.Proxy != "" {
+		if _, err := parseProxyURL(options.Proxy); err != nil {
+			v.addErr("Proxy", err)
+		}
+		if options.ProxyFunc != nil {
+			v.add("Proxy", "conflicts with ProxyFunc")
+		}
+	}
+	if options.UnixSocket != "" && (options.Proxy != "" || options.ProxyFunc != nil) {
+		v.add("UnixSocket", "conflicts with Proxy and ProxyFunc")
+	}
+	if len(options.ProxyConnectHeaders) > 0 && options.Proxy == "" && options.ProxyFunc == nil {
+		v.add("ProxyConnectHeaders", "requires Proxy or ProxyFunc")
+	}
+
+	v.errs = append(v.errs, loadTLSFiles(options, &tls.Config{})...)
+	if options.Insecure && options.CAFile != "" {
+		v.add("CAFile", "conflicts with Insecure")
+	}
+
+	if rp := options.RedirectPolicy; rp != nil {
+		v.notNegative("RedirectPolicy.MaxRedirects", int64(rp.MaxRedirects))
+		if options.MaxRedirects != 0 {
+			v.add("MaxRedirects", "conflicts with RedirectPolicy")
+		}
+	}
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+type optionsValidator struct {
+	errs OptionErrors
+}
+
+func (v *optionsValidator) addErr(field string, err error) {
+	v.errs = append(v.errs, &OptionError{Field: field, Err: err})
+}
+
+func (v *optionsValidator) add(field, msg string) {
+	v.addErr(field, errors.New(msg))
+}
+
+func (v *optionsValidator) notNegative(field string, value int64) {
+	if value < 0 {
+		v.add(field, "must not be negative")
+	}
+}
+
+func (v *optionsValidator) rateLimit(field string, limit RateLimit) {
+	if limit.Rate < 0 {
+		v.add(field+".Rate", "must not be negative")
+	}
+	v.notNegative(field+".Burst", int64(limit.Burst))
+}
+
+// sortedKeys returns the keys of m, a map with string keys, in order.
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// loadTLSFiles adds the CA and client certificate files of options to
+// config, returning the files that could not be loaded.
+func loadTLSFiles(options Options, config *tls.Config) OptionErrors {
+	var errs OptionErrors
+
+	if options.CAFile != "" {
+		pem, err := ioutil.ReadFile(options.CAFile)
+		if err != nil {
+			errs = append(errs, &OptionError{Field: "CAFile", Err: err})
+		} else {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				errs = append(errs, &OptionError{Field: "CAFile", Err: errors.New("no PEM certificate found")})
+			} else {
+				config.RootCAs = pool
+			}
+		}
+	}
+
+	switch {
+	case options.CertFile == "" && options.KeyFile == "":
+	case options.CertFile == "":
+		errs = append(errs, &OptionError{Field: "CertFile", Err: errors.New("required with KeyFile")})
+	case options.KeyFile == "":
+		errs = append(errs, &OptionError{Field: "KeyFile", Err: errors.New("required with CertFile")})
+	default:
+		cert, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			errs = append(errs, &OptionError{Field: "CertFile", Err: err})
+		} else {
+			config.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	return errs
+}
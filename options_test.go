@@ -0,0 +1,119 @@
+package goreq
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestOptionsValidation(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("NewClientE", func() {
+		var ts *httptest.Server
+		var dir string
+
+		g.Before(func() {
+			ts = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("secure"))
+			}))
+			dir, _ = ioutil.TempDir("", "goreq-options")
+		})
+
+		g.After(func() {
+			ts.Close()
+			os.RemoveAll(dir)
+		})
+
+		fields := func(err error) []string {
+			var fields []string
+			for _, e := range err.(OptionErrors) {
+				fields = append(fields, e.Field)
+			}
+			return fields
+		}
+
+		g.It("Should return a client for valid options", func() {
+			client, err := NewClientE(Options{Timeout: time.Second, MaxConcurrentPerHost: 2, QueueTimeout: time.Second})
+			Expect(err).Should(BeNil())
+			Expect(client.Timeout).Should(Equal(time.Second))
+		})
+
+		g.It("Should report every invalid option with its field", func() {
+			client, err := NewClientE(Options{
+				Timeout:          -time.Second,
+				Proxy:            "ftp://proxy",
+				MaxQueuedPerHost: 10,
+				HostRateLimits:   map[string]RateLimit{"b.com": {Rate: -1}, "a.com": {Burst: -1}},
+				CircuitBreaker:   &CircuitBreaker{FailureRate: 2},
+				LoadBalancer:     &LoadBalancer{Endpoints: []Endpoint{{URL: "http://"}}},
+				DialContext:      (&net.Dialer{}).DialContext,
+				UnixSocket:       "/var/run/docker.sock",
+				KeyFile:          "client.key",
+			})
+
+			Expect(client).Should(BeNil())
+			Expect(fields(err)).Should(Equal([]string{
+				"Timeout",
+				"HostRateLimits[a.com].Burst",
+				"HostRateLimits[b.com].Rate",
+				"MaxQueuedPerHost",
+				"CircuitBreaker.FailureRate",
+				"LoadBalancer.Endpoints[0].URL",
+				"DialContext",
+				"Proxy",
+				"UnixSocket",
+				"CertFile",
+			}))
+			Expect(err.Error()).Should(ContainSubstring("GoReq: option Timeout: must not be negative"))
+		})
+
+		g.It("Should report conflicting options", func() {
+			_, err := NewClientE(Options{
+				CoalesceHeaders:   []string{"X-Tenant"},
+				RateLimitFailFast: true,
+				Insecure:          true,
+				CAFile:            filepath.Join(dir, "missing.pem"),
+			})
+			Expect(fields(err)).Should(Equal([]string{"CoalesceHeaders", "RateLimitFailFast", "CAFile", "CAFile"}))
+		})
+
+		g.It("Should trust the certificate authorities of CAFile", func() {
+			caFile := filepath.Join(dir, "ca.pem")
+			ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0644)
+
+			client, err := NewClientE(Options{CAFile: caFile})
+			Expect(err).Should(BeNil())
+			res, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("secure"))
+
+			invalid := filepath.Join(dir, "invalid.pem")
+			ioutil.WriteFile(invalid, []byte("not a certificate"), 0644)
+			_, err = NewClientE(Options{CAFile: invalid})
+			Expect(fields(err)).Should(Equal([]string{"CAFile"}))
+
+			_, err = NewClient(Options{CAFile: invalid}).Do(Request{Uri: ts.URL})
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	g.Describe("AddProxyConnectHeader", func() {
+		g.It("Should add the header to the options", func() {
+			options := Options{}
+			options.AddProxyConnectHeader("X-Proxy-Token", "secret")
+			Expect(options.ProxyConnectHeaders.Get("X-Proxy-Token")).Should(Equal("secret"))
+		})
+	})
+}
@@ -0,0 +1,76 @@
+package goreq
+
+import (
+	"io"
+	"time"
+)
+
+const defaultProgressInterval = 100 * time.Millisecond
+
+// Progress reports how much of a body has been transferred. Bytes counts
+// the logical (uncompressed) bytes and WireBytes the bytes sent or received
+// on the connection; they only differ when Compression is used. Total is
+// the expected number of wire bytes, taken from ContentLength, or -1 when
+// unknown.
+type Progress struct {
+	Bytes     int64
+	WireBytes int64
+	Total     int64
+	Done      bool
+}
+
+type progressTracker struct {
+	fn       func(Progress)
+	interval time.Duration
+	total    int64
+	last     time.Time
+}
+
+func newProgressTracker(fn func(Progress), interval time.Duration, total int64) *progressTracker {
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	return &progressTracker{fn: fn, interval: interval, total: total}
+}
+
+func (t *progressTracker) report(logical, wire int64, done bool) {
+	now := time.Now()
+	if !done && now.Sub(t.last) < t.interval {
+		return
+	}
+	t.last = now
+	t.fn(Progress{Bytes: logical, WireBytes: wire, Total: t.total, Done: done})
+}
+
+// uploadProgress wraps a request body. When the body was compressed up
+// front, logical bytes are estimated from the overall compression ratio.
+type uploadProgress struct {
+	reader       io.ReadCloser
+	tracker      *progressTracker
+	logicalTotal int64
+	wire         int64
+	done         bool
+}
+
+func (u *uploadProgress) Read(p []byte) (int, error) {
+	n, err := u.reader.Read(p)
+	u.wire += int64(n)
+	if err == io.EOF && !u.done {
+		u.done = true
+		u.tracker.report(u.logical(), u.wire, true)
+	} else if n > 0 {
+		u.tracker.report(u.logical(), u.wire, false)
+	}
+	return n, err
+}
+
+func (u *uploadProgress) logical() int64 {
+	if u.logicalTotal < 0 || u.tracker.total <= 0 {
+		return u.wire
+	}
+	return u.wire * u.logicalTotal / u.tracker.total
+}
+
+func (u *uploadProgress) Close() error {
+	return u.reader.Close()
+}
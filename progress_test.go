@@ -0,0 +1,132 @@
+package goreq
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestProgress(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Progress callbacks", func() {
+		var ts *httptest.Server
+		payload := strings.Repeat("goreq", 20000)
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ioutil.ReadAll(r.Body)
+				if r.URL.Path == "/gzip" {
+					var buf bytes.Buffer
+					gw := gzip.NewWriter(&buf)
+					gw.Write([]byte(payload))
+					gw.Close()
+					w.Header().Set("Content-Encoding", "gzip")
+					w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+					w.Write(buf.Bytes())
+					return
+				}
+				w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+				w.Write([]byte(payload))
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should report upload progress with the expected total", func() {
+			var reports []Progress
+			_, err := NewClient(Options{}).Do(Request{
+				Method:           "POST",
+				Uri:              ts.URL,
+				Body:             payload,
+				OnUploadProgress: func(p Progress) { reports = append(reports, p) },
+			})
+
+			Expect(err).Should(BeNil())
+			Expect(len(reports) > 0).Should(BeTrue())
+			last := reports[len(reports)-1]
+			Expect(last.Done).Should(BeTrue())
+			Expect(last.Bytes).Should(Equal(int64(len(payload))))
+			Expect(last.WireBytes).Should(Equal(int64(len(payload))))
+			Expect(last.Total).Should(Equal(int64(len(payload))))
+		})
+
+		g.It("Should report logical and wire bytes for compressed uploads", func() {
+			var last Progress
+			_, err := NewClient(Options{}).Do(Request{
+				Method:           "POST",
+				Uri:              ts.URL,
+				Body:             payload,
+				Compression:      Gzip(),
+				OnUploadProgress: func(p Progress) { last = p },
+			})
+
+			Expect(err).Should(BeNil())
+			Expect(last.Done).Should(BeTrue())
+			Expect(last.Bytes).Should(Equal(int64(len(payload))))
+			Expect(last.WireBytes < last.Bytes).Should(BeTrue())
+			Expect(last.WireBytes).Should(Equal(last.Total))
+		})
+
+		g.It("Should report download progress", func() {
+			var reports []Progress
+			res, err := NewClient(Options{}).Do(Request{
+				Uri:                ts.URL,
+				OnDownloadProgress: func(p Progress) { reports = append(reports, p) },
+			})
+			Expect(err).Should(BeNil())
+			res.Body.ToString()
+
+			last := reports[len(reports)-1]
+			Expect(last.Done).Should(BeTrue())
+			Expect(last.Bytes).Should(Equal(int64(len(payload))))
+			Expect(last.Total).Should(Equal(int64(len(payload))))
+		})
+
+		g.It("Should report logical and wire bytes for compressed downloads", func() {
+			var reports []Progress
+			res, err := NewClient(Options{}).Do(Request{
+				Uri:                ts.URL + "/gzip",
+				Compression:        Gzip(),
+				OnDownloadProgress: func(p Progress) { reports = append(reports, p) },
+			})
+			Expect(err).Should(BeNil())
+			res.Body.ToString()
+
+			last := reports[len(reports)-1]
+			Expect(last.Done).Should(BeTrue())
+			Expect(last.Bytes).Should(Equal(int64(len(payload))))
+			Expect(last.WireBytes).Should(Equal(last.Total))
+			Expect(last.WireBytes < last.Bytes).Should(BeTrue())
+		})
+
+		g.It("Should throttle reports", func() {
+			var reports []Progress
+			res, err := NewClient(Options{}).Do(Request{
+				Uri:                ts.URL,
+				OnDownloadProgress: func(p Progress) { reports = append(reports, p) },
+			})
+			Expect(err).Should(BeNil())
+
+			buf := make([]byte, 10)
+			for {
+				if _, err := res.Body.Read(buf); err != nil {
+					break
+				}
+			}
+			Expect(len(reports) < 10).Should(BeTrue())
+			Expect(reports[len(reports)-1].Done).Should(BeTrue())
+		})
+	})
+}
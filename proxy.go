@@ -0,0 +1,141 @@
+package goreq
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// parseProxyURL parses the URL of an HTTP, HTTPS or SOCKS5 proxy. With
+// socks5h:// the proxy resolves host names; Go's transport does the same
+// for socks5://.
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("GoReq: unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("GoReq: proxy URL without host")
+	}
+	return u, nil
+}
+
+// proxyFunc returns the Proxy of the transport: proxy, except for Unix
+// sockets and the hosts matching Options.NoProxy.
+func proxyFunc(options Options, proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return skipUnixSockets(bypassProxy(options.NoProxy, proxy))
+}
+
+// noProxyRule is an entry of a NO_PROXY style list: "*", an IP address, a
+// CIDR block or a domain, optionally with a port. Domains match their
+// subdomains too, and only them when they start with "." or "*.".
+type noProxyRule struct {
+	all            bool
+	network        *net.IPNet
+	ip             net.IP
+	domain         string
+	subdomainsOnly bool
+	port           string
+}
+
+func parseNoProxy(entries []string) []noProxyRule {
+	var rules []noProxyRule
+	for _, entry := range entries {
+		for _, value := range strings.Split(entry, ",") {
+			value = strings.ToLower(strings.TrimSpace(value))
+			if value == "" {
+				continue
+			}
+			rules = append(rules, parseNoProxyRule(value))
+		}
+	}
+	return rules
+}
+
+func parseNoProxyRule(value string) noProxyRule {
+	if value == "*" {
+		return noProxyRule{all: true}
+	}
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return noProxyRule{network: network}
+	}
+	if ip := net.ParseIP(value); ip != nil {
+		return noProxyRule{ip: ip}
+	}
+
+	var rule noProxyRule
+	if host, port, err := net.SplitHostPort(value); err == nil {
+		value, rule.port = host, port
+	}
+	if ip := net.ParseIP(strings.Trim(value, "[]")); ip != nil {
+		rule.ip = ip
+		return rule
+	}
+	switch {
+	case strings.HasPrefix(value, "*."):
+		rule.domain, rule.subdomainsOnly = value[2:], true
+	case strings.HasPrefix(value, "."):
+		rule.domain, rule.subdomainsOnly = value[1:], true
+	default:
+		rule.domain = value
+	}
+	return rule
+}
+
+func (rule noProxyRule) match(host, port string) bool {
+	if rule.all {
+		return true
+	}
+	if rule.port != "" && rule.port != port {
+		return false
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case rule.network != nil:
+		return ip != nil && rule.network.Contains(ip)
+	case rule.ip != nil:
+		return ip != nil && rule.ip.Equal(ip)
+	case rule.subdomainsOnly:
+		return strings.HasSuffix(host, "."+rule.domain)
+	}
+	return host == rule.domain || strings.HasSuffix(host, "."+rule.domain)
+}
+
+// bypassProxy sends the requests to hosts matching noProxy directly.
+func bypassProxy(noProxy []string, proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	rules := parseNoProxy(noProxy)
+	if len(rules) == 0 {
+		return proxy
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if matchHost(rules, req.URL) {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+}
+
+// matchHost reports whether the host of u matches one of rules.
+func matchHost(rules []noProxyRule, u *url.URL) bool {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	for _, rule := range rules {
+		if rule.match(host, port) {
+			return true
+		}
+	}
+	return false
+}
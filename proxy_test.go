@@ -0,0 +1,210 @@
+package goreq
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+// socks5Server is a minimal SOCKS5 proxy with username/password
+// authentication, resolving the hosts in hosts.
+type socks5Server struct {
+	listener net.Listener
+	user     string
+	password string
+	hosts    map[string]string
+	connects int32
+}
+
+func newSOCKS5Server(user, password string, hosts map[string]string) *socks5Server {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	s := &socks5Server{listener: listener, user: user, password: password, hosts: hosts}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *socks5Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	methods := make([]byte, header[1])
+	io.ReadFull(conn, methods)
+	conn.Write([]byte{5, 2})
+
+	io.ReadFull(conn, header)
+	user := make([]byte, header[1])
+	io.ReadFull(conn, user)
+	length := make([]byte, 1)
+	io.ReadFull(conn, length)
+	password := make([]byte, length[0])
+	io.ReadFull(conn, password)
+	if string(user) != s.user || string(password) != s.password {
+		conn.Write([]byte{1, 1})
+		return
+	}
+	conn.Write([]byte{1, 0})
+
+	request := make([]byte, 4)
+	io.ReadFull(conn, request)
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		io.ReadFull(conn, length)
+		name := make([]byte, length[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	io.ReadFull(conn, port)
+	if address, ok := s.hosts[host]; ok {
+		host = address
+	}
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	atomic.AddInt32(&s.connects, 1)
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+func TestProxySelection(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Proxy selection", func() {
+		var ts *httptest.Server
+		var proxy *httptest.Server
+		var proxied int32
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("direct " + r.Host))
+			}))
+			proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&proxied, 1)
+				w.Write([]byte("proxy " + r.Host))
+			}))
+		})
+
+		g.BeforeEach(func() {
+			atomic.StoreInt32(&proxied, 0)
+		})
+
+		g.After(func() {
+			ts.Close()
+			proxy.Close()
+		})
+
+		g.It("Should tunnel through a socks5h proxy with authentication", func() {
+			target, _ := url.Parse(ts.URL)
+			socks := newSOCKS5Server("user", "secret", map[string]string{"backend.test": target.Hostname()})
+			defer socks.listener.Close()
+
+			client := NewClient(Options{Proxy: "socks5h://user:secret@" + socks.listener.Addr().String()})
+			res, err := client.Do(Request{Uri: "http://backend.test:" + target.Port() + "/"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("direct backend.test:" + target.Port()))
+			Expect(atomic.LoadInt32(&socks.connects)).Should(Equal(int32(1)))
+
+			client = NewClient(Options{Proxy: "socks5://user:wrong@" + socks.listener.Addr().String()})
+			_, err = client.Do(Request{Uri: ts.URL})
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		g.It("Should choose the proxy of each request with ProxyFunc", func() {
+			proxyURL, _ := url.Parse(proxy.URL)
+			client := NewClient(Options{ProxyFunc: func(req *http.Request) (*url.URL, error) {
+				if req.URL.Path == "/via-proxy" {
+					return proxyURL, nil
+				}
+				return nil, nil
+			}})
+
+			res, err := client.Do(Request{Uri: ts.URL + "/via-proxy"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(HavePrefix("proxy "))
+
+			res, err = client.Do(Request{Uri: ts.URL + "/direct"})
+			Expect(err).Should(BeNil())
+			body, _ = res.Body.ToString()
+			Expect(body).Should(HavePrefix("direct "))
+		})
+
+		g.It("Should bypass the proxy for hosts in NoProxy", func() {
+			target, _ := url.Parse(ts.URL)
+			client := NewClient(Options{Proxy: proxy.URL, NoProxy: []string{"example.com, " + target.Hostname() + ":" + target.Port()}})
+
+			res, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(HavePrefix("direct "))
+			Expect(atomic.LoadInt32(&proxied)).Should(Equal(int32(0)))
+		})
+
+		g.It("Should match NoProxy entries", func() {
+			rules := parseNoProxy([]string{"example.com", ".internal", "10.0.0.0/8", "::1", "api.test:8443"})
+			match := func(host, port string) bool {
+				for _, rule := range rules {
+					if rule.match(host, port) {
+						return true
+					}
+				}
+				return false
+			}
+
+			Expect(match("example.com", "80")).Should(BeTrue())
+			Expect(match("www.example.com", "443")).Should(BeTrue())
+			Expect(match("notexample.com", "80")).Should(BeFalse())
+			Expect(match("svc.internal", "80")).Should(BeTrue())
+			Expect(match("internal", "80")).Should(BeFalse())
+			Expect(match("10.1.2.3", "80")).Should(BeTrue())
+			Expect(match("11.1.2.3", "80")).Should(BeFalse())
+			Expect(match("::1", "80")).Should(BeTrue())
+			Expect(match("api.test", "8443")).Should(BeTrue())
+			Expect(match("api.test", "443")).Should(BeFalse())
+			Expect(parseNoProxy([]string{"*"})[0].match("anything", "80")).Should(BeTrue())
+		})
+
+		g.It("Should return invalid proxies when sending requests", func() {
+			for _, proxy := range []string{"ftp://proxy:21", "http://[::1", "socks5://"} {
+				_, err := NewClient(Options{Proxy: proxy}).Do(Request{Uri: ts.URL})
+				Expect(err).ShouldNot(BeNil())
+				Expect(err.(*Error).Err.Error()).ShouldNot(BeEmpty())
+			}
+		})
+	})
+}
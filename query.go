@@ -0,0 +1,181 @@
+package goreq
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FieldError represents a query-string value that could not be converted
+// into its struct field.
+type FieldError struct {
+	Field string
+	Name  string
+	Value string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("GoReq: field %s (%s=%q): %v", e.Field, e.Name, e.Value, e.Err)
+}
+
+// DecodeErrors collects every FieldError found while decoding a query string.
+type DecodeErrors []*FieldError
+
+func (e DecodeErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// EncodeQuery encodes a struct into url.Values following the same `url`
+// tags used by Request.QueryString.
+func EncodeQuery(query interface{}) (url.Values, error) {
+	switch query.(type) {
+	case url.Values:
+		return query.(url.Values), nil
+	case *url.Values:
+		return *query.(*url.Values), nil
+	}
+	v := url.Values{}
+	err := paramParseStruct(&v, query)
+	return v, err
+}
+
+// DecodeQuery fills the struct pointed by dst with values, using the same
+// `url` tags accepted by EncodeQuery. Conversion failures are returned as
+// DecodeErrors, one entry per field.
+func DecodeQuery(values url.Values, dst interface{}) error {
+	s := reflect.ValueOf(dst)
+	if s.Kind() != reflect.Ptr || s.IsNil() {
+		return errors.New("GoReq: DecodeQuery requires a non-nil pointer to struct")
+	}
+	for s.Kind() == reflect.Ptr {
+		if s.IsNil() {
+			s.Set(reflect.New(s.Type().Elem()))
+		}
+		s = s.Elem()
+	}
+	if s.Kind() != reflect.Struct {
+		return errors.New("GoReq: DecodeQuery requires a non-nil pointer to struct")
+	}
+
+	var errs DecodeErrors
+	paramDecodeStruct(values, s, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func paramDecodeStruct(values url.Values, s reflect.Value, errs *DecodeErrors) {
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := s.Field(i)
+		typeField := t.Field(i)
+
+		if !field.CanSet() {
+			continue
+		}
+
+		urlTag := typeField.Tag.Get("url")
+		if urlTag == "-" {
+			continue
+		}
+
+		name, opts := parseTag(urlTag)
+
+		if opts.Contains("squash") {
+			for field.Kind() == reflect.Ptr {
+				if field.IsNil() {
+					field.Set(reflect.New(field.Type().Elem()))
+				}
+				field = field.Elem()
+			}
+			if field.Kind() == reflect.Struct {
+				paramDecodeStruct(values, field, errs)
+			}
+			continue
+		}
+
+		if urlTag == "" {
+			name = strings.ToLower(typeField.Name)
+		}
+
+		vals, ok := values[name]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+
+		if err := setField(field, vals); err != nil {
+			*errs = append(*errs, &FieldError{
+				Field: typeField.Name,
+				Name:  name,
+				Value: strings.Join(vals, ","),
+				Err:   err,
+			})
+		}
+	}
+}
+
+func setField(field reflect.Value, vals []string) error {
+	switch field.Kind() {
+	case reflect.Ptr:
+		v := reflect.New(field.Type().Elem())
+		if err := setField(v.Elem(), vals); err != nil {
+			return err
+		}
+		field.Set(v)
+		return nil
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setValue(slice.Index(i), val); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	default:
+		return setValue(field, vals[0])
+	}
+}
+
+func setValue(field reflect.Value, val string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(val, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
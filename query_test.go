@@ -0,0 +1,134 @@
+package goreq
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestQuery(t *testing.T) {
+	type Paging struct {
+		Limit int `url:"limit"`
+		Skip  int `url:"skip,omitempty"`
+	}
+
+	type Search struct {
+		Paging  `url:",squash"`
+		Term    string   `url:"q"`
+		Tags    []string `url:"tag"`
+		Exact   bool
+		Score   *float64 `url:"score"`
+		Ignored string   `url:"-"`
+		hidden  string
+	}
+
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Query encoding and decoding", func() {
+		g.It("Should encode a struct into url.Values", func() {
+			values, err := EncodeQuery(Search{Term: "go", Exact: true, Paging: Paging{Limit: 10}})
+			Expect(err).Should(BeNil())
+			Expect(values.Get("q")).Should(Equal("go"))
+			Expect(values.Get("exact")).Should(Equal("true"))
+			Expect(values.Get("limit")).Should(Equal("10"))
+		})
+
+		g.It("Should decode url.Values into a struct", func() {
+			values := url.Values{}
+			values.Set("q", "go")
+			values.Set("limit", "10")
+			values.Set("skip", "20")
+			values.Set("exact", "true")
+			values.Set("score", "1.5")
+			values.Add("tag", "a")
+			values.Add("tag", "b")
+			values.Set("ignored", "x")
+
+			var s Search
+			err := DecodeQuery(values, &s)
+			Expect(err).Should(BeNil())
+			Expect(s.Term).Should(Equal("go"))
+			Expect(s.Limit).Should(Equal(10))
+			Expect(s.Skip).Should(Equal(20))
+			Expect(s.Exact).Should(BeTrue())
+			Expect(*s.Score).Should(Equal(1.5))
+			Expect(s.Tags).Should(Equal([]string{"a", "b"}))
+			Expect(s.Ignored).Should(Equal(""))
+		})
+
+		g.It("Should round trip scalar fields", func() {
+			type Filter struct {
+				Paging `url:",squash"`
+				Term   string `url:"q"`
+				Exact  bool
+			}
+			in := Filter{Term: "x", Exact: true, Paging: Paging{Limit: 1, Skip: 2}}
+			values, err := EncodeQuery(in)
+			Expect(err).Should(BeNil())
+
+			var out Filter
+			Expect(DecodeQuery(values, &out)).Should(BeNil())
+			Expect(out).Should(Equal(in))
+		})
+
+		g.It("Should round trip slice and pointer fields", func() {
+			score := 1.5
+			in := Search{Term: "go", Tags: []string{"a", "b"}, Score: &score, Paging: Paging{Limit: 10}}
+			values, err := EncodeQuery(in)
+			Expect(err).Should(BeNil())
+			Expect(values["tag"]).Should(Equal([]string{"a", "b"}))
+			Expect(values.Get("score")).Should(Equal("1.5"))
+
+			var out Search
+			Expect(DecodeQuery(values, &out)).Should(BeNil())
+			Expect(out).Should(Equal(in))
+
+			values, err = EncodeQuery(Search{})
+			Expect(err).Should(BeNil())
+			Expect(values).ShouldNot(HaveKey("score"))
+			Expect(values).ShouldNot(HaveKey("tag"))
+		})
+
+		g.It("Should skip nil squashed pointers", func() {
+			type Outer struct {
+				*Paging `url:",squash"`
+				B       int `url:"b"`
+			}
+			values, err := EncodeQuery(Outer{B: 1})
+			Expect(err).Should(BeNil())
+			Expect(values).Should(Equal(url.Values{"b": {"1"}}))
+
+			var out Outer
+			Expect(DecodeQuery(values, &out)).Should(BeNil())
+			values, err = EncodeQuery(out)
+			Expect(err).Should(BeNil())
+			Expect(values).Should(Equal(url.Values{"b": {"1"}, "limit": {"0"}, "skip": {"0"}}))
+		})
+
+		g.It("Should report conversion errors per field", func() {
+			values := url.Values{}
+			values.Set("limit", "ten")
+			values.Set("exact", "maybe")
+			values.Set("q", "ok")
+
+			var s Search
+			err := DecodeQuery(values, &s)
+			Expect(err).ShouldNot(BeNil())
+			errs, ok := err.(DecodeErrors)
+			Expect(ok).Should(BeTrue())
+			Expect(errs).Should(HaveLen(2))
+			Expect(errs[0].Field).Should(Equal("Limit"))
+			Expect(errs[0].Value).Should(Equal("ten"))
+			Expect(errs[1].Field).Should(Equal("Exact"))
+			Expect(s.Term).Should(Equal("ok"))
+		})
+
+		g.It("Should reject non pointer destinations", func() {
+			var s Search
+			Expect(DecodeQuery(url.Values{}, s)).ShouldNot(BeNil())
+		})
+	})
+}
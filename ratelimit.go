@@ -0,0 +1,212 @@
+package goreq
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultRateLimitPause = time.Second
+
+// RateLimit configures a token bucket: Rate requests per second with bursts
+// of up to Burst requests (1 when unset). A zero Rate means unlimited.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitError is returned when a request would exceed the rate limit of
+// its host and Options.RateLimitFailFast is set.
+type RateLimitError struct {
+	Host       string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("GoReq: rate limit exceeded for %s, retry after %s", e.Host, e.RetryAfter)
+}
+
+type rateLimiter struct {
+	defaultLimit RateLimit
+	hosts        map[string]RateLimit
+	failFast     bool
+	now          func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(options Options) *rateLimiter {
+	if options.RateLimit.Rate <= 0 && len(options.HostRateLimits) == 0 {
+		return nil
+	}
+	return &rateLimiter{
+		defaultLimit: options.RateLimit,
+		hosts:        options.HostRateLimits,
+		failFast:     options.RateLimitFailFast,
+		now:          time.Now,
+		buckets:      map[string]*tokenBucket{},
+	}
+}
+
+func (l *rateLimiter) bucket(host string) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[host]; ok {
+		return b
+	}
+	limit, ok := l.hosts[host]
+	if !ok {
+		limit = l.defaultLimit
+	}
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	b := &tokenBucket{rate: limit.Rate, burst: burst, tokens: burst, last: l.now()}
+	l.buckets[host] = b
+	return b
+}
+
+func (l *rateLimiter) wrap(next roundTripFunc) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		host := req.URL.Host
+		b := l.bucket(host)
+
+		wait, ok := b.reserve(l.now(), l.failFast)
+		if !ok {
+			return nil, &RateLimitError{Host: host, RetryAfter: wait}
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				b.cancel()
+				return nil, req.Context().Err()
+			}
+		}
+
+		res, err := next(req)
+		if err == nil {
+			b.adapt(res, l.now())
+		}
+		return res, err
+	}
+}
+
+type tokenBucket struct {
+	mu           sync.Mutex
+	rate         float64
+	burst        float64
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// reserve takes a token, returning how long the caller must wait before
+// using it. With failFast nothing is taken when a wait would be needed.
+func (b *tokenBucket) reserve(now time.Time, failFast bool) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+
+	var wait time.Duration
+	if b.blockedUntil.After(now) {
+		wait = b.blockedUntil.Sub(now)
+	}
+	if b.rate > 0 && b.tokens < 1 {
+		if tokenWait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second)); tokenWait > wait {
+			wait = tokenWait
+		}
+	}
+	if failFast && wait > 0 {
+		return wait, false
+	}
+	if b.rate > 0 {
+		b.tokens--
+	}
+	return wait, true
+}
+
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+1)
+	}
+}
+
+// adapt pauses the bucket when the server says its quota is exhausted,
+// either with a 429 or with a remaining count of zero.
+func (b *tokenBucket) adapt(res *http.Response, now time.Time) {
+	var pause time.Duration
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		pause = retryAfter(res.Header, now)
+		if pause <= 0 {
+			pause = rateLimitReset(res.Header, now)
+		}
+		if pause <= 0 {
+			pause = defaultRateLimitPause
+		}
+	case headerValue(res.Header, "RateLimit-Remaining", "X-RateLimit-Remaining") == "0":
+		pause = rateLimitReset(res.Header, now)
+	}
+	if pause <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until := now.Add(pause); until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+}
+
+func headerValue(h http.Header, names ...string) string {
+	for _, name := range names {
+		if value := h.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// retryAfter parses Retry-After as seconds or as an HTTP date.
+func retryAfter(h http.Header, now time.Time) time.Duration {
+	value := h.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// rateLimitReset parses RateLimit-Reset or X-RateLimit-Reset, accepting
+// both delta seconds and unix timestamps.
+func rateLimitReset(h http.Header, now time.Time) time.Duration {
+	value := headerValue(h, "RateLimit-Reset", "X-RateLimit-Reset")
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	if seconds > 1000000000 {
+		return time.Unix(seconds, 0).Sub(now)
+	}
+	return time.Duration(seconds) * time.Second
+}
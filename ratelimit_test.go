@@ -0,0 +1,167 @@
+package goreq
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+// fakeClock is a clock for the now hooks of the limiter, the breaker and
+// the balancer that only moves when told to.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Unix(1500000000, 0)}
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestRateLimit(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Rate limiting", func() {
+		var ts *httptest.Server
+		var limited int32
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/429":
+					if atomic.AddInt32(&limited, 1) == 1 {
+						w.Header().Set("Retry-After", "1")
+						w.WriteHeader(429)
+						return
+					}
+				case "/remaining":
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", "1")
+				}
+				w.WriteHeader(200)
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should space requests according to the rate", func() {
+			clock := newFakeClock()
+			limiter := newRateLimiter(Options{RateLimit: RateLimit{Rate: 10}})
+			limiter.now = clock.now
+			bucket := limiter.bucket("example.com")
+
+			for i := 0; i < 5; i++ {
+				wait, ok := bucket.reserve(clock.now(), false)
+				Expect(ok).Should(BeTrue())
+				Expect(wait).Should(BeNumerically("~", time.Duration(i)*100*time.Millisecond, time.Microsecond))
+			}
+
+			clock.advance(500 * time.Millisecond)
+			wait, _ := bucket.reserve(clock.now(), false)
+			Expect(wait).Should(Equal(time.Duration(0)))
+		})
+
+		g.It("Should allow bursts", func() {
+			clock := newFakeClock()
+			limiter := newRateLimiter(Options{RateLimit: RateLimit{Rate: 1, Burst: 5}, RateLimitFailFast: true})
+			limiter.now = clock.now
+			send := limiter.wrap(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: 200, Header: http.Header{}}, nil
+			})
+			req, _ := http.NewRequest("GET", ts.URL, nil)
+
+			for i := 0; i < 5; i++ {
+				_, err := send(req)
+				Expect(err).Should(BeNil())
+			}
+			_, err := send(req)
+			Expect(err.(*RateLimitError).RetryAfter).Should(Equal(time.Second))
+
+			clock.advance(time.Second)
+			_, err = send(req)
+			Expect(err).Should(BeNil())
+		})
+
+		g.It("Should fail fast with a RateLimitError", func() {
+			client := NewClient(Options{RateLimit: RateLimit{Rate: 1}, RateLimitFailFast: true})
+
+			_, err := client.Do(Request{Uri: ts.URL})
+			Expect(err).Should(BeNil())
+
+			_, err = client.Do(Request{Uri: ts.URL})
+			Expect(err).ShouldNot(BeNil())
+			rlErr, ok := err.(*Error).Err.(*RateLimitError)
+			Expect(ok).Should(BeTrue())
+			Expect(rlErr.RetryAfter > 0).Should(BeTrue())
+		})
+
+		g.It("Should use per host limits", func() {
+			u, _ := url.Parse(ts.URL)
+			client := NewClient(Options{
+				RateLimit:         RateLimit{Rate: 1},
+				HostRateLimits:    map[string]RateLimit{u.Host: {Rate: 1000, Burst: 10}},
+				RateLimitFailFast: true,
+			})
+
+			for i := 0; i < 5; i++ {
+				_, err := client.Do(Request{Uri: ts.URL})
+				Expect(err).Should(BeNil())
+			}
+		})
+
+		g.It("Should stop waiting when the context is done", func() {
+			client := NewClient(Options{RateLimit: RateLimit{Rate: 0.1}})
+			client.Do(Request{Uri: ts.URL})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err := client.Do(Request{Uri: ts.URL, Context: ctx})
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.(*Error).Timeout()).Should(BeTrue())
+		})
+
+		g.It("Should pause the host after a 429", func() {
+			client := NewClient(Options{RateLimit: RateLimit{Rate: 1000, Burst: 10}, RateLimitFailFast: true})
+
+			res, err := client.Do(Request{Uri: ts.URL + "/429"})
+			Expect(err).Should(BeNil())
+			Expect(res.StatusCode).Should(Equal(429))
+
+			_, err = client.Do(Request{Uri: ts.URL + "/429"})
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.(*Error).Err.(*RateLimitError).RetryAfter > 900*time.Millisecond).Should(BeTrue())
+		})
+
+		g.It("Should pause the host when no requests remain", func() {
+			client := NewClient(Options{RateLimit: RateLimit{Rate: 1000, Burst: 10}, RateLimitFailFast: true})
+
+			_, err := client.Do(Request{Uri: ts.URL + "/remaining"})
+			Expect(err).Should(BeNil())
+
+			_, err = client.Do(Request{Uri: ts.URL})
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+}
@@ -0,0 +1,109 @@
+package goreq
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultPolicyMaxRedirects = 10
+
+// RedirectError is returned when the RedirectPolicy refuses to follow a
+// redirect.
+type RedirectError struct {
+	URL    string
+	Reason string
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("GoReq: redirect to %s refused: %s", e.URL, e.Reason)
+}
+
+// RedirectPolicy configures the redirects followed by the client.
+//
+// Up to MaxRedirects (10 when unset) redirects are followed. With SameHost
+// or AllowedHosts, redirects may only leave the host of the original
+// request for the hosts in AllowedHosts, whose entries work like
+// Options.NoProxy. Redirects from HTTPS to HTTP are refused unless
+// AllowDowngrade is set.
+//
+// When a redirect leaves the original host, the Authorization and Cookie
+// headers are dropped unless ForwardAuthorization and ForwardCookies are
+// set, and so are the DropHeaders; the other headers are forwarded. 307
+// and 308 redirects keep the method and the body of the request, provided
+// it can be sent again, which is the case for string, []byte and JSON
+// bodies.
+type RedirectPolicy struct {
+	MaxRedirects         int
+	SameHost             bool
+	AllowedHosts         []string
+	AllowDowngrade       bool
+	ForwardAuthorization bool
+	ForwardCookies       bool
+	DropHeaders          []string
+}
+
+// Redirect is a hop of the redirect chain of a response: the URL that
+// answered with a redirect, its status and the URL it redirected to.
+type Redirect struct {
+	URL        string
+	StatusCode int
+	Location   string
+}
+
+func (p *RedirectPolicy) checkRedirect() func(req *http.Request, via []*http.Request) error {
+	maxRedirects := p.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultPolicyMaxRedirects
+	}
+	allowed := parseNoProxy(p.AllowedHosts)
+
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return &RedirectError{URL: req.URL.String(), Reason: fmt.Sprintf("more than %d redirects", maxRedirects)}
+		}
+		first, last := via[0], via[len(via)-1]
+		if last.URL.Scheme == "https" && req.URL.Scheme != "https" && !p.AllowDowngrade {
+			return &RedirectError{URL: req.URL.String(), Reason: "downgrade from https"}
+		}
+		if strings.EqualFold(req.URL.Hostname(), first.URL.Hostname()) {
+			return nil
+		}
+
+		if (p.SameHost || len(allowed) > 0) && !matchHost(allowed, req.URL) {
+			return &RedirectError{URL: req.URL.String(), Reason: "host not allowed"}
+		}
+		if p.ForwardAuthorization {
+			if values, ok := first.Header["Authorization"]; ok {
+				req.Header["Authorization"] = values
+			}
+		}
+		if p.ForwardCookies {
+			if values, ok := first.Header["Cookie"]; ok {
+				req.Header["Cookie"] = values
+			}
+		}
+		for _, name := range p.DropHeaders {
+			req.Header.Del(name)
+		}
+		return nil
+	}
+}
+
+// Redirects returns the redirects followed to get the response, oldest
+// first.
+func (r *Response) Redirects() []Redirect {
+	var redirects []Redirect
+	if r.Response == nil {
+		return redirects
+	}
+	for req := r.Response.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		previous := req.Response
+		redirects = append([]Redirect{{
+			URL:        previous.Request.URL.String(),
+			StatusCode: previous.StatusCode,
+			Location:   req.URL.String(),
+		}}, redirects...)
+	}
+	return redirects
+}
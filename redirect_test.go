@@ -0,0 +1,152 @@
+package goreq
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestRedirectPolicyOptions(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("RedirectPolicy", func() {
+		var ts, other, secure *httptest.Server
+		var otherURL string
+
+		g.Before(func() {
+			other = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, "auth=%s tenant=%s trace=%s", r.Header.Get("Authorization"), r.Header.Get("X-Tenant"), r.Header.Get("X-Trace"))
+			}))
+			u, _ := url.Parse(other.URL)
+			otherURL = "http://localhost:" + u.Port()
+
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/301":
+					http.Redirect(w, r, "/302", 301)
+				case "/302":
+					http.Redirect(w, r, "/final", 302)
+				case "/loop":
+					http.Redirect(w, r, "/loop", 302)
+				case "/other":
+					http.Redirect(w, r, otherURL+"/", 302)
+				case "/307", "/308":
+					code := 307
+					if r.URL.Path == "/308" {
+						code = 308
+					}
+					http.Redirect(w, r, "/echo", code)
+				case "/echo":
+					body, _ := ioutil.ReadAll(r.Body)
+					fmt.Fprintf(w, "%s %s", r.Method, body)
+				default:
+					fmt.Fprint(w, "final")
+				}
+			}))
+
+			secure = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, ts.URL+"/final", 302)
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+			other.Close()
+			secure.Close()
+		})
+
+		g.It("Should follow redirects and record the chain", func() {
+			client := NewClient(Options{RedirectPolicy: &RedirectPolicy{}})
+
+			res, err := client.Do(Request{Uri: ts.URL + "/301"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("final"))
+			Expect(res.Redirects()).Should(Equal([]Redirect{
+				{URL: ts.URL + "/301", StatusCode: 301, Location: ts.URL + "/302"},
+				{URL: ts.URL + "/302", StatusCode: 302, Location: ts.URL + "/final"},
+			}))
+		})
+
+		g.It("Should stop after MaxRedirects", func() {
+			client := NewClient(Options{RedirectPolicy: &RedirectPolicy{MaxRedirects: 3}})
+
+			_, err := client.Do(Request{Uri: ts.URL + "/loop"})
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(ContainSubstring("more than 3 redirects"))
+			redirectErr := err.(*Error).Err.(*url.Error).Err.(*RedirectError)
+			Expect(redirectErr.URL).Should(Equal(ts.URL + "/loop"))
+		})
+
+		g.It("Should restrict redirects to the same or allowed hosts", func() {
+			client := NewClient(Options{RedirectPolicy: &RedirectPolicy{SameHost: true}})
+			_, err := client.Do(Request{Uri: ts.URL + "/other"})
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(ContainSubstring("host not allowed"))
+
+			client = NewClient(Options{RedirectPolicy: &RedirectPolicy{AllowedHosts: []string{"localhost"}}})
+			res, err := client.Do(Request{Uri: ts.URL + "/other"})
+			Expect(err).Should(BeNil())
+			Expect(res.StatusCode).Should(Equal(200))
+		})
+
+		g.It("Should refuse downgrades from https unless allowed", func() {
+			client := NewClient(Options{Insecure: true, RedirectPolicy: &RedirectPolicy{}})
+			_, err := client.Do(Request{Uri: secure.URL})
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(ContainSubstring("downgrade from https"))
+
+			client = NewClient(Options{Insecure: true, RedirectPolicy: &RedirectPolicy{AllowDowngrade: true}})
+			res, err := client.Do(Request{Uri: secure.URL})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("final"))
+		})
+
+		g.It("Should choose the headers forwarded to other hosts", func() {
+			request := Request{Uri: ts.URL + "/other"}
+			request.AddHeader("Authorization", "Bearer token")
+			request.AddHeader("X-Tenant", "acme")
+			request.AddHeader("X-Trace", "abc")
+
+			res, err := NewClient(Options{RedirectPolicy: &RedirectPolicy{}}).Do(request)
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("auth= tenant=acme trace=abc"))
+
+			res, err = NewClient(Options{RedirectPolicy: &RedirectPolicy{
+				ForwardAuthorization: true,
+				DropHeaders:          []string{"X-Tenant"},
+			}}).Do(request)
+			Expect(err).Should(BeNil())
+			body, _ = res.Body.ToString()
+			Expect(body).Should(Equal("auth=Bearer token tenant= trace=abc"))
+		})
+
+		g.It("Should keep the method and body on 307 and 308", func() {
+			client := NewClient(Options{RedirectPolicy: &RedirectPolicy{}})
+
+			for _, path := range []string{"/307", "/308"} {
+				res, err := client.Do(Request{Method: "POST", Uri: ts.URL + path, Body: "payload"})
+				Expect(err).Should(BeNil())
+				body, _ := res.Body.ToString()
+				Expect(body).Should(Equal("POST payload"))
+				Expect(strings.HasSuffix(res.Redirects()[0].URL, path)).Should(BeTrue())
+			}
+		})
+
+		g.It("Should conflict with MaxRedirects", func() {
+			_, err := NewClientE(Options{MaxRedirects: 2, RedirectPolicy: &RedirectPolicy{}})
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.(OptionErrors)[0].Field).Should(Equal("MaxRedirects"))
+		})
+	})
+}
@@ -0,0 +1,151 @@
+package goreq
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver gives the endpoints of logical hosts to LoadBalancer. Resolve
+// returns nil endpoints and a nil error for the hosts it does not know,
+// whose requests are then sent as they are.
+type Resolver interface {
+	Resolve(ctx context.Context, host string) ([]Endpoint, error)
+}
+
+// StaticResolver resolves logical hosts from a fixed list.
+type StaticResolver map[string][]Endpoint
+
+// Resolve returns the endpoints listed for host.
+func (r StaticResolver) Resolve(ctx context.Context, host string) ([]Endpoint, error) {
+	return r[host], nil
+}
+
+// SRVResolver resolves logical hosts with DNS SRV records. With Service
+// and Proto set the record of "_Service._Proto.host" is looked up, and the
+// record of host itself otherwise. Only the targets with the lowest
+// priority are used, weighted as in the records, with Scheme ("http" when
+// unset).
+type SRVResolver struct {
+	Service  string
+	Proto    string
+	Scheme   string
+	Resolver *net.Resolver
+
+	lookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Resolve looks up the SRV records of host. Hosts without records are not
+// logical ones.
+func (r *SRVResolver) Resolve(ctx context.Context, host string) ([]Endpoint, error) {
+	lookup := r.lookupSRV
+	if lookup == nil {
+		resolver := r.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		lookup = resolver.LookupSRV
+	}
+
+	_, records, err := lookup(ctx, r.Service, r.Proto, host)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	scheme := r.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	priority := records[0].Priority
+	for _, record := range records {
+		if record.Priority < priority {
+			priority = record.Priority
+		}
+	}
+
+	var endpoints []Endpoint
+	for _, record := range records {
+		if record.Priority != priority {
+			continue
+		}
+		target := strings.TrimSuffix(record.Target, ".")
+		endpoints = append(endpoints, Endpoint{
+			URL:    scheme + "://" + net.JoinHostPort(target, strconv.Itoa(int(record.Port))),
+			Weight: int(record.Weight),
+		})
+	}
+	return endpoints, nil
+}
+
+// FileResolver resolves logical hosts from a JSON file mapping each host
+// to its endpoints, such as
+//
+//	{"orders-service": [{"URL": "http://10.0.0.1:8080", "Weight": 2}]}
+//
+// The file is read again whenever it changes. A file that cannot be read
+// or parsed leaves the previous endpoints in place.
+type FileResolver struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	hosts   map[string][]Endpoint
+}
+
+// NewFileResolver returns a FileResolver reading path, which must exist
+// and be valid.
+func NewFileResolver(path string) (*FileResolver, error) {
+	r := &FileResolver{path: path}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Resolve returns the endpoints listed for host in the file.
+func (r *FileResolver) Resolve(ctx context.Context, host string) ([]Endpoint, error) {
+	r.reload()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hosts[host], nil
+}
+
+func (r *FileResolver) reload() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.hosts != nil && info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	var hosts map[string][]Endpoint
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return err
+	}
+	if hosts == nil {
+		hosts = map[string][]Endpoint{}
+	}
+	r.hosts, r.modTime, r.size = hosts, info.ModTime(), info.Size()
+	return nil
+}
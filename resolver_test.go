@@ -0,0 +1,213 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+type resolverFunc func(ctx context.Context, host string) ([]Endpoint, error)
+
+func (f resolverFunc) Resolve(ctx context.Context, host string) ([]Endpoint, error) {
+	return f(ctx, host)
+}
+
+func TestResolver(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Resolver", func() {
+		var servers []*httptest.Server
+		var dir string
+
+		g.Before(func() {
+			for i := 0; i < 2; i++ {
+				i := i
+				servers = append(servers, httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintf(w, "%d %s", i, r.URL.Path)
+				})))
+			}
+			dir, _ = ioutil.TempDir("", "goreq-resolver")
+		})
+
+		g.After(func() {
+			for _, ts := range servers {
+				ts.Close()
+			}
+			os.RemoveAll(dir)
+		})
+
+		g.It("Should send logical hosts to the endpoints of a static resolver", func() {
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{Resolver: StaticResolver{
+				"orders-service": {{URL: servers[0].URL}},
+				"users-service":  {{URL: servers[1].URL}},
+			}}})
+			defer client.Close()
+
+			res, err := client.Do(Request{Uri: "http://orders-service/v1/orders"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("0 /v1/orders"))
+
+			res, err = client.Do(Request{Uri: "http://users-service/v1/users"})
+			Expect(err).Should(BeNil())
+			body, _ = res.Body.ToString()
+			Expect(body).Should(Equal("1 /v1/users"))
+
+			res, err = client.Do(Request{Uri: servers[1].URL + "/direct"})
+			Expect(err).Should(BeNil())
+			body, _ = res.Body.ToString()
+			Expect(body).Should(Equal("1 /direct"))
+
+			states := client.Endpoints()
+			Expect(states).Should(HaveLen(2))
+			Expect(states[0].Host).Should(Equal("orders-service"))
+			Expect(states[1].Host).Should(Equal("users-service"))
+		})
+
+		g.It("Should return resolver errors for logical hosts", func() {
+			failure := errors.New("lookup failed")
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{
+				LogicalHosts: []string{"orders-service"},
+				Resolver: resolverFunc(func(ctx context.Context, host string) ([]Endpoint, error) {
+					return nil, failure
+				}),
+			}})
+			defer client.Close()
+
+			_, err := client.Do(Request{Uri: "http://orders-service/"})
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.(*Error).Err).Should(Equal(failure))
+		})
+
+		g.It("Should only ask the resolver for logical hosts when they are listed", func() {
+			var asked []string
+			var mu sync.Mutex
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{
+				LogicalHosts: []string{".svc"},
+				Resolver: resolverFunc(func(ctx context.Context, host string) ([]Endpoint, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					asked = append(asked, host)
+					return []Endpoint{{URL: servers[0].URL}}, nil
+				}),
+			}})
+			defer client.Close()
+
+			res, err := client.Do(Request{Uri: "http://orders.svc/v1"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("0 /v1"))
+
+			res, err = client.Do(Request{Uri: servers[1].URL + "/direct"})
+			Expect(err).Should(BeNil())
+			body, _ = res.Body.ToString()
+			Expect(body).Should(Equal("1 /direct"))
+
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(asked).Should(Equal([]string{"orders.svc"}))
+		})
+
+		g.It("Should send requests as they are when the resolver fails for unlisted hosts", func() {
+			var calls int32
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{
+				RefreshInterval: 10 * time.Millisecond,
+				Resolver: resolverFunc(func(ctx context.Context, host string) ([]Endpoint, error) {
+					if atomic.AddInt32(&calls, 1) == 1 {
+						return nil, errors.New("SERVFAIL")
+					}
+					return nil, nil
+				}),
+			}})
+			defer client.Close()
+
+			for i := 0; i < 3; i++ {
+				res, err := client.Do(Request{Uri: servers[1].URL + "/direct"})
+				Expect(err).Should(BeNil())
+				body, _ := res.Body.ToString()
+				Expect(body).Should(Equal("1 /direct"))
+			}
+			Consistently(func() int32 { return atomic.LoadInt32(&calls) }, 50*time.Millisecond).Should(Equal(int32(2)))
+		})
+
+		g.It("Should refresh the endpoints of a file resolver when the file changes", func() {
+			path := filepath.Join(dir, "endpoints.json")
+			write := func(server *httptest.Server, modTime time.Time) {
+				ioutil.WriteFile(path, []byte(fmt.Sprintf(`{"orders-service": [{"url": %q}]}`, server.URL)), 0644)
+				os.Chtimes(path, modTime, modTime)
+			}
+			write(servers[0], time.Now().Add(-time.Minute))
+
+			resolver, err := NewFileResolver(path)
+			Expect(err).Should(BeNil())
+			client := NewClient(Options{LoadBalancer: &LoadBalancer{Resolver: resolver, RefreshInterval: 20 * time.Millisecond}})
+			defer client.Close()
+
+			res, err := client.Do(Request{Uri: "http://orders-service/"})
+			Expect(err).Should(BeNil())
+			body, _ := res.Body.ToString()
+			Expect(body).Should(Equal("0 /"))
+
+			write(servers[1], time.Now())
+			Eventually(func() string {
+				res, err := client.Do(Request{Uri: "http://orders-service/"})
+				if err != nil {
+					return err.Error()
+				}
+				body, _ := res.Body.ToString()
+				return body
+			}).Should(Equal("1 /"))
+		})
+
+		g.It("Should fail to create a file resolver with an invalid file", func() {
+			path := filepath.Join(dir, "invalid.json")
+			ioutil.WriteFile(path, []byte("{"), 0644)
+
+			_, err := NewFileResolver(path)
+			Expect(err).ShouldNot(BeNil())
+			_, err = NewFileResolver(filepath.Join(dir, "missing.json"))
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		g.It("Should resolve the lowest priority SRV records", func() {
+			resolver := &SRVResolver{Service: "http", Proto: "tcp"}
+			resolver.lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				Expect(service).Should(Equal("http"))
+				Expect(proto).Should(Equal("tcp"))
+				if name != "orders.service.consul" {
+					return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+				}
+				return "", []*net.SRV{
+					{Target: "10.0.0.1.", Port: 8080, Priority: 1, Weight: 3},
+					{Target: "10.0.0.2.", Port: 8081, Priority: 1, Weight: 1},
+					{Target: "10.0.0.3.", Port: 8082, Priority: 2, Weight: 1},
+				}, nil
+			}
+
+			endpoints, err := resolver.Resolve(context.Background(), "orders.service.consul")
+			Expect(err).Should(BeNil())
+			Expect(endpoints).Should(Equal([]Endpoint{
+				{URL: "http://10.0.0.1:8080", Weight: 3},
+				{URL: "http://10.0.0.2:8081", Weight: 1},
+			}))
+
+			endpoints, err = resolver.Resolve(context.Background(), "example.com")
+			Expect(err).Should(BeNil())
+			Expect(endpoints).Should(BeNil())
+		})
+	})
+}
@@ -0,0 +1,220 @@
+package goreq
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultSegments       = 4
+	defaultSegmentRetries = 3
+)
+
+// offsetWriter writes sequentially into an io.WriterAt starting at offset.
+type offsetWriter struct {
+	dst    io.WriterAt
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.dst.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+// progressWriterAt reports the bytes written by every segment through a
+// single tracker, so the callback sees the totals of the whole object and
+// is never called concurrently.
+type progressWriterAt struct {
+	dst     io.WriterAt
+	mu      sync.Mutex
+	tracker *progressTracker
+	written int64
+}
+
+func (w *progressWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.dst.WriteAt(p, off)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written += int64(n)
+	w.tracker.report(w.written, w.written, false)
+	return n, err
+}
+
+func (w *progressWriterAt) done() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.tracker.report(w.written, w.written, true)
+}
+
+// DownloadSegments fetches request into dst using up to segments concurrent
+// Range requests over the same client. The resource is probed with a HEAD
+// request first; when the server does not advertise "Accept-Ranges: bytes"
+// or a Content-Length, it falls back to a single GET. Each segment is
+// retried individually, resuming from the last byte written, and the
+// strong ETag, or Last-Modified, from the HEAD response guards every range
+// with If-Range. It returns the number of bytes written.
+// OnDownloadProgress reports the progress of the whole object, summed
+// across segments.
+func (client Client) DownloadSegments(ctx context.Context, request Request, dst io.WriterAt, segments int) (int64, error) {
+	if segments <= 0 {
+		segments = defaultSegments
+	}
+
+	// The progress callbacks apply to the object, not to each request.
+	segment := request
+	segment.OnDownloadProgress, segment.OnUploadProgress = nil, nil
+
+	head := segment.clone(ctx)
+	head.Method = "HEAD"
+	head.Body = nil
+	res, err := client.Do(head)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	size := res.ContentLength
+	if res.StatusCode != 200 || size <= 0 || !strings.Contains(res.Header.Get("Accept-Ranges"), "bytes") {
+		return client.downloadWhole(ctx, request, dst)
+	}
+	validator := rangeValidator(res.Header)
+
+	if int64(segments) > size {
+		segments = int(size)
+	}
+	segmentSize := size / int64(segments)
+
+	var progress *progressWriterAt
+	if request.OnDownloadProgress != nil {
+		progress = &progressWriterAt{dst: dst, tracker: newProgressTracker(request.OnDownloadProgress, request.ProgressInterval, size)}
+		dst = progress
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for i := 0; i < segments; i++ {
+		start := int64(i) * segmentSize
+		end := start + segmentSize - 1
+		if i == segments-1 {
+			end = size - 1
+		}
+
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := client.downloadSegment(ctx, segment, dst, start, end, validator); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	if progress != nil {
+		progress.done()
+	}
+	return size, nil
+}
+
+// DownloadSegmentsToFile runs DownloadSegments into path+".part" and
+// renames it to path once every segment is complete.
+func (client Client) DownloadSegmentsToFile(ctx context.Context, request Request, path string, segments int) error {
+	partial := path + partialSuffix
+	file, err := os.Create(partial)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.DownloadSegments(ctx, request, file, segments)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, path)
+}
+
+func (client Client) downloadSegment(ctx context.Context, request Request, dst io.WriterAt, start, end int64, validator string) error {
+	var err error
+	for attempt := 0; attempt <= defaultSegmentRetries; attempt++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var n int64
+		n, err = client.fetchRange(ctx, request, dst, start, end, validator)
+		start += n
+		if err == nil && start > end {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("GoReq: short segment, %d bytes missing", end-start+1)
+		}
+		if _, fatal := err.(*segmentError); fatal {
+			return err
+		}
+	}
+	return err
+}
+
+// segmentError is a failure that retrying the segment will not fix.
+type segmentError struct {
+	msg string
+}
+
+func (e *segmentError) Error() string {
+	return e.msg
+}
+
+func (client Client) fetchRange(ctx context.Context, request Request, dst io.WriterAt, start, end int64, validator string) (int64, error) {
+	req := request.clone(ctx)
+	req.AddHeader("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if validator != "" {
+		req.AddHeader("If-Range", validator)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 206 {
+		return 0, &segmentError{fmt.Sprintf("GoReq: range request returned status %d, the resource may have changed", res.StatusCode)}
+	}
+	if got, _, ok := parseContentRange(res.Header.Get("Content-Range")); !ok || got != start {
+		return 0, &segmentError{fmt.Sprintf("GoReq: unexpected Content-Range %q for offset %d", res.Header.Get("Content-Range"), start)}
+	}
+
+	return io.Copy(&offsetWriter{dst: dst, offset: start}, io.LimitReader(res.Body, end-start+1))
+}
+
+func (client Client) downloadWhole(ctx context.Context, request Request, dst io.WriterAt) (int64, error) {
+	res, err := client.Do(request.clone(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return 0, fmt.Errorf("GoReq: download failed with status %d", res.StatusCode)
+	}
+	return io.Copy(&offsetWriter{dst: dst}, res.Body)
+}
@@ -0,0 +1,158 @@
+package goreq
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+type bufferAt struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *bufferAt) WriteAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if end := int(off) + len(p); end > len(b.buf) {
+		b.buf = append(b.buf, make([]byte, end-len(b.buf))...)
+	}
+	return copy(b.buf[off:], p), nil
+}
+
+func TestDownloadSegments(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Segmented downloads", func() {
+		var ts *httptest.Server
+		var mu sync.Mutex
+		var ranges []string
+		var failed bool
+		content := []byte(strings.Repeat("abcdefghij", 10000))
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				if r.Method == "GET" {
+					ranges = append(ranges, r.Header.Get("Range"))
+				}
+				fail := r.URL.Path == "/flaky" && r.Method == "GET" && !failed
+				if fail {
+					failed = true
+				}
+				mu.Unlock()
+
+				switch r.URL.Path {
+				case "/norange":
+					w.Write(content)
+				case "/weak":
+					w.Header().Set("ETag", `W/"v1"`)
+					http.ServeContent(w, r, "file", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), bytes.NewReader(content))
+				case "/flaky":
+					if fail {
+						w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-99/%d", len(content)))
+						w.Header().Set("Content-Length", "100")
+						w.WriteHeader(206)
+						w.Write(content[:10])
+						return
+					}
+					fallthrough
+				default:
+					w.Header().Set("ETag", `"v1"`)
+					http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+				}
+			}))
+		})
+
+		g.BeforeEach(func() {
+			ranges = nil
+			failed = false
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should download ranges concurrently and reassemble them", func() {
+			dst := &bufferAt{}
+			n, err := NewClient(Options{}).DownloadSegments(context.Background(), Request{Uri: ts.URL}, dst, 4)
+
+			Expect(err).Should(BeNil())
+			Expect(n).Should(Equal(int64(len(content))))
+			Expect(dst.buf).Should(Equal(content))
+			Expect(ranges).Should(HaveLen(4))
+		})
+
+		g.It("Should report the progress of the whole object from one place", func() {
+			var reports []Progress
+			request := Request{Uri: ts.URL, ProgressInterval: time.Nanosecond, OnDownloadProgress: func(p Progress) {
+				reports = append(reports, p)
+			}}
+			_, err := NewClient(Options{}).DownloadSegments(context.Background(), request, &bufferAt{}, 4)
+			Expect(err).Should(BeNil())
+
+			last := reports[len(reports)-1]
+			Expect(last).Should(Equal(Progress{Bytes: int64(len(content)), WireBytes: int64(len(content)), Total: int64(len(content)), Done: true}))
+			for i, p := range reports[:len(reports)-1] {
+				Expect(p.Total).Should(Equal(int64(len(content))))
+				Expect(p.Done).Should(BeFalse())
+				if i > 0 {
+					Expect(p.Bytes).Should(BeNumerically(">=", reports[i-1].Bytes))
+				}
+			}
+		})
+
+		g.It("Should download from servers with weak ETags", func() {
+			dst := &bufferAt{}
+			n, err := NewClient(Options{}).DownloadSegments(context.Background(), Request{Uri: ts.URL + "/weak"}, dst, 4)
+
+			Expect(err).Should(BeNil())
+			Expect(n).Should(Equal(int64(len(content))))
+			Expect(dst.buf).Should(Equal(content))
+		})
+
+		g.It("Should fall back to a single request without range support", func() {
+			dst := &bufferAt{}
+			n, err := NewClient(Options{}).DownloadSegments(context.Background(), Request{Uri: ts.URL + "/norange"}, dst, 4)
+
+			Expect(err).Should(BeNil())
+			Expect(n).Should(Equal(int64(len(content))))
+			Expect(dst.buf).Should(Equal(content))
+			Expect(ranges).Should(Equal([]string{""}))
+		})
+
+		g.It("Should retry a failed segment from where it stopped", func() {
+			dst := &bufferAt{}
+			_, err := NewClient(Options{}).DownloadSegments(context.Background(), Request{Uri: ts.URL + "/flaky"}, dst, 1)
+
+			Expect(err).Should(BeNil())
+			Expect(dst.buf).Should(Equal(content))
+			Expect(ranges).Should(Equal([]string{fmt.Sprintf("bytes=0-%d", len(content)-1), fmt.Sprintf("bytes=10-%d", len(content)-1)}))
+		})
+
+		g.It("Should write into a file", func() {
+			dir, _ := ioutil.TempDir("", "goreq")
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "file")
+
+			err := NewClient(Options{}).DownloadSegmentsToFile(context.Background(), Request{Uri: ts.URL}, path, 3)
+			Expect(err).Should(BeNil())
+
+			b, _ := ioutil.ReadFile(path)
+			Expect(b).Should(Equal(content))
+		})
+	})
+}
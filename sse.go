@@ -0,0 +1,208 @@
+package goreq
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultEventRetry   = 3 * time.Second
+	maxEventLineSize    = 1 << 20
+	defaultEventType    = "message"
+	eventStreamMimeType = "text/event-stream"
+)
+
+// Event is a single Server-Sent Event.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// EventReader parses Server-Sent Events from a response body.
+type EventReader struct {
+	scanner *bufio.Scanner
+	lastID  string
+	retry   time.Duration
+}
+
+// NewEventReader returns an EventReader over res.Body.
+func NewEventReader(res *Response) *EventReader {
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 4096), maxEventLineSize)
+	scanner.Split(scanEventLines)
+	return &EventReader{scanner: scanner}
+}
+
+// LastEventID returns the last event id seen on the stream.
+func (r *EventReader) LastEventID() string {
+	return r.lastID
+}
+
+// Retry returns the reconnection time last sent by the server, or zero.
+func (r *EventReader) Retry() time.Duration {
+	return r.retry
+}
+
+// Next returns the next event, or io.EOF when the stream ends.
+func (r *EventReader) Next() (*Event, error) {
+	var data bytes.Buffer
+	event := &Event{}
+	hasData := false
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if line == "" {
+			if !hasData {
+				event = &Event{}
+				continue
+			}
+			event.ID = r.lastID
+			event.Data = strings.TrimSuffix(data.String(), "\n")
+			if event.Event == "" {
+				event.Event = defaultEventType
+			}
+			return event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				r.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				r.retry = time.Duration(ms) * time.Millisecond
+				event.Retry = r.retry
+			}
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// scanEventLines splits on CRLF, LF or CR as required by the event stream format.
+func scanEventLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\r' {
+			if i+1 < len(data) {
+				if data[i+1] == '\n' {
+					return i + 2, data[:i], nil
+				}
+				return i + 1, data[:i], nil
+			}
+			if !atEOF {
+				return 0, nil, nil
+			}
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// Subscribe connects to an event stream and calls handler for every event,
+// reconnecting when the stream ends or fails. Reconnections send the
+// Last-Event-ID header and wait for the retry interval sent by the server,
+// except after a timeout, when they are immediate. It returns when ctx is
+// done, when handler returns an error, or when the server answers with
+// anything other than 200 OK (204 No Content stops the subscription
+// without error).
+//
+// The client Timeout does not apply to streams, which last as long as ctx.
+func (client Client) Subscribe(ctx context.Context, request Request, handler func(*Event) error) error {
+	lastID := ""
+	retry := defaultEventRetry
+	streamCtx := context.WithValue(ctx, noClientTimeoutKey{}, true)
+
+	for {
+		timedOut := false
+		req := request.clone(streamCtx)
+		if req.Accept == "" {
+			req.Accept = eventStreamMimeType
+		}
+		req.AddHeader("Cache-Control", "no-cache")
+		if lastID != "" {
+			req.AddHeader("Last-Event-ID", lastID)
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			timedOut = isTimeout(err)
+		} else {
+			if res.StatusCode == 204 {
+				res.Body.Close()
+				return nil
+			}
+			if res.StatusCode != 200 {
+				res.Body.Close()
+				return &Error{Err: fmt.Errorf("GoReq: event stream returned status %d", res.StatusCode)}
+			}
+
+			reader := NewEventReader(res)
+			reader.lastID = lastID
+			for {
+				event, err := reader.Next()
+				if err != nil {
+					timedOut = isTimeout(err)
+					break
+				}
+				if err := handler(event); err != nil {
+					res.Body.Close()
+					return err
+				}
+			}
+			res.Body.Close()
+			lastID = reader.LastEventID()
+			if reader.Retry() > 0 {
+				retry = reader.Retry()
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if timedOut {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	t, ok := err.(itimeout)
+	return ok && t.Timeout()
+}
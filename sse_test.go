@@ -0,0 +1,153 @@
+package goreq
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestServerSentEvents(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Server-Sent Events", func() {
+		var ts *httptest.Server
+		var mu sync.Mutex
+		var lastEventIDs []string
+		var slowConnections int
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/stream":
+					w.Header().Set("Content-Type", "text/event-stream")
+					fmt.Fprint(w, ": comment\r\nevent: greeting\r\ndata: hello\r\ndata:  world\r\nid: 1\r\n\r\n")
+					fmt.Fprint(w, "data: second\nretry: 1500\n\n")
+					fmt.Fprint(w, "id: 7\n\n")
+					fmt.Fprint(w, "data\n\n")
+				case "/reconnect":
+					mu.Lock()
+					lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+					n := len(lastEventIDs)
+					mu.Unlock()
+					if n > 2 {
+						w.WriteHeader(204)
+						return
+					}
+					w.Header().Set("Content-Type", "text/event-stream")
+					fmt.Fprintf(w, "retry: 10\nid: %d\ndata: event %d\n\n", n, n)
+				case "/forever":
+					w.Header().Set("Content-Type", "text/event-stream")
+					fmt.Fprint(w, "retry: 10\ndata: tick\n\n")
+				case "/slow":
+					mu.Lock()
+					slowConnections++
+					mu.Unlock()
+					w.Header().Set("Content-Type", "text/event-stream")
+					for i := 1; i <= 3; i++ {
+						fmt.Fprintf(w, "data: tick %d\n\n", i)
+						w.(http.Flusher).Flush()
+						time.Sleep(100 * time.Millisecond)
+					}
+				case "/missing":
+					w.WriteHeader(404)
+				}
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should parse event, data, id and retry fields", func() {
+			res, err := NewClient(Options{}).Do(Request{Uri: ts.URL + "/stream"})
+			Expect(err).Should(BeNil())
+
+			reader := NewEventReader(res)
+
+			event, err := reader.Next()
+			Expect(err).Should(BeNil())
+			Expect(event.Event).Should(Equal("greeting"))
+			Expect(event.Data).Should(Equal("hello\n world"))
+			Expect(event.ID).Should(Equal("1"))
+
+			event, err = reader.Next()
+			Expect(err).Should(BeNil())
+			Expect(event.Event).Should(Equal("message"))
+			Expect(event.Data).Should(Equal("second"))
+			Expect(event.ID).Should(Equal("1"))
+			Expect(event.Retry).Should(Equal(1500 * time.Millisecond))
+
+			event, err = reader.Next()
+			Expect(err).Should(BeNil())
+			Expect(event.Data).Should(Equal(""))
+			Expect(event.ID).Should(Equal("7"))
+
+			_, err = reader.Next()
+			Expect(err).Should(Equal(io.EOF))
+			Expect(reader.LastEventID()).Should(Equal("7"))
+			Expect(reader.Retry()).Should(Equal(1500 * time.Millisecond))
+		})
+
+		g.It("Should reconnect sending Last-Event-ID until the server answers 204", func() {
+			var events []string
+			err := NewClient(Options{}).Subscribe(context.Background(), Request{Uri: ts.URL + "/reconnect"}, func(e *Event) error {
+				events = append(events, e.Data)
+				return nil
+			})
+
+			Expect(err).Should(BeNil())
+			Expect(events).Should(Equal([]string{"event 1", "event 2"}))
+			Expect(lastEventIDs).Should(Equal([]string{"", "1", "2"}))
+		})
+
+		g.It("Should stop when the context is cancelled", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			count := 0
+			err := NewClient(Options{}).Subscribe(ctx, Request{Uri: ts.URL + "/forever"}, func(e *Event) error {
+				count++
+				return nil
+			})
+
+			Expect(err).Should(Equal(context.DeadlineExceeded))
+			Expect(count > 1).Should(BeTrue())
+		})
+
+		g.It("Should keep streams open beyond the client timeout", func() {
+			var events []string
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			err := NewClient(Options{Timeout: 150 * time.Millisecond}).Subscribe(ctx, Request{Uri: ts.URL + "/slow"}, func(e *Event) error {
+				events = append(events, e.Data)
+				if len(events) == 3 {
+					return io.EOF
+				}
+				return nil
+			})
+
+			Expect(err).Should(Equal(io.EOF))
+			Expect(events).Should(Equal([]string{"tick 1", "tick 2", "tick 3"}))
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(slowConnections).Should(Equal(1))
+		})
+
+		g.It("Should fail on unexpected status codes", func() {
+			err := NewClient(Options{}).Subscribe(context.Background(), Request{Uri: ts.URL + "/missing"}, func(e *Event) error {
+				return nil
+			})
+
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+}
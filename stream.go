@@ -0,0 +1,133 @@
+package goreq
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode"
+)
+
+// JSONStreamError reports a decoding failure for one element of a JSON stream.
+type JSONStreamError struct {
+	Index int
+	Err   error
+}
+
+func (e *JSONStreamError) Error() string {
+	return fmt.Sprintf("GoReq: decoding JSON element %d: %v", e.Index, e.Err)
+}
+
+// JSONStream iterates over the elements of a JSON array or of
+// newline-delimited JSON values, one element at a time.
+type JSONStream struct {
+	reader  *bufio.Reader
+	dec     *json.Decoder
+	array   bool
+	started bool
+	done    bool
+	index   int
+	raw     json.RawMessage
+	err     error
+}
+
+// JSONStream returns an iterator over the body. The framing is detected
+// from the first byte: a top-level array is streamed element by element,
+// anything else is read as a sequence of JSON values (NDJSON).
+func (b *Body) JSONStream() *JSONStream {
+	return &JSONStream{reader: bufio.NewReader(b.Reader()), index: -1}
+}
+
+// EachJSON calls fn with every element of the body, stopping at the first
+// error returned by fn or by the decoder.
+func (b *Body) EachJSON(fn func(index int, raw json.RawMessage) error) error {
+	stream := b.JSONStream()
+	for stream.Next() {
+		if err := fn(stream.Index(), stream.Raw()); err != nil {
+			return err
+		}
+	}
+	return stream.Err()
+}
+
+func (s *JSONStream) start() error {
+	s.started = true
+	for {
+		r, _, err := s.reader.ReadRune()
+		if err == io.EOF {
+			s.done = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		s.array = r == '['
+		s.reader.UnreadRune()
+		break
+	}
+	s.dec = json.NewDecoder(s.reader)
+	if s.array {
+		_, err := s.dec.Token()
+		return err
+	}
+	return nil
+}
+
+// Next reads the next element, returning false at the end of the stream or
+// on error.
+func (s *JSONStream) Next() bool {
+	if s.done || s.err != nil {
+		return false
+	}
+	if !s.started {
+		if err := s.start(); err != nil {
+			s.err = &JSONStreamError{Index: 0, Err: err}
+			return false
+		}
+		if s.done {
+			return false
+		}
+	}
+	if !s.dec.More() {
+		s.done = true
+		if s.array {
+			if _, err := s.dec.Token(); err != nil {
+				s.err = &JSONStreamError{Index: s.index + 1, Err: err}
+			}
+		}
+		return false
+	}
+	s.index++
+	s.raw = nil
+	if err := s.dec.Decode(&s.raw); err != nil {
+		s.err = &JSONStreamError{Index: s.index, Err: err}
+		return false
+	}
+	return true
+}
+
+// Decode unmarshals the current element into v.
+func (s *JSONStream) Decode(v interface{}) error {
+	if err := json.Unmarshal(s.raw, v); err != nil {
+		return &JSONStreamError{Index: s.index, Err: err}
+	}
+	return nil
+}
+
+// Raw returns the current element undecoded.
+func (s *JSONStream) Raw() json.RawMessage {
+	return s.raw
+}
+
+// Index returns the position of the current element, starting at zero.
+func (s *JSONStream) Index() int {
+	return s.index
+}
+
+// Err returns the error that stopped the stream, if any.
+func (s *JSONStream) Err() error {
+	return s.err
+}
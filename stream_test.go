@@ -0,0 +1,105 @@
+package goreq
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestJSONStream(t *testing.T) {
+	type Item struct {
+		ID int `json:"id"`
+	}
+
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("JSON streams", func() {
+		var ts *httptest.Server
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+				switch r.URL.Path {
+				case "/array":
+					fmt.Fprint(w, ` [{"id":1}, {"id":2},{"id":3}] `)
+				case "/ndjson":
+					fmt.Fprint(w, "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n")
+				case "/empty":
+					fmt.Fprint(w, "[]")
+				case "/broken":
+					fmt.Fprint(w, `[{"id":1},{"id":"two"},{"id":3`)
+				}
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should iterate over a JSON array", func() {
+			res, err := NewClient(Options{}).Do(Request{Uri: ts.URL + "/array"})
+			Expect(err).Should(BeNil())
+
+			var ids []int
+			stream := res.Body.JSONStream()
+			for stream.Next() {
+				var item Item
+				Expect(stream.Decode(&item)).Should(BeNil())
+				Expect(stream.Index()).Should(Equal(len(ids)))
+				ids = append(ids, item.ID)
+			}
+			Expect(stream.Err()).Should(BeNil())
+			Expect(ids).Should(Equal([]int{1, 2, 3}))
+		})
+
+		g.It("Should iterate over NDJSON", func() {
+			res, err := NewClient(Options{}).Do(Request{Uri: ts.URL + "/ndjson"})
+			Expect(err).Should(BeNil())
+
+			var ids []int
+			err = res.Body.EachJSON(func(index int, raw json.RawMessage) error {
+				var item Item
+				if err := json.Unmarshal(raw, &item); err != nil {
+					return err
+				}
+				ids = append(ids, item.ID)
+				return nil
+			})
+			Expect(err).Should(BeNil())
+			Expect(ids).Should(Equal([]int{1, 2, 3}))
+		})
+
+		g.It("Should handle empty arrays", func() {
+			res, err := NewClient(Options{}).Do(Request{Uri: ts.URL + "/empty"})
+			Expect(err).Should(BeNil())
+
+			stream := res.Body.JSONStream()
+			Expect(stream.Next()).Should(BeFalse())
+			Expect(stream.Err()).Should(BeNil())
+		})
+
+		g.It("Should report the index of elements that fail to decode", func() {
+			res, err := NewClient(Options{}).Do(Request{Uri: ts.URL + "/broken"})
+			Expect(err).Should(BeNil())
+
+			stream := res.Body.JSONStream()
+			var item Item
+			Expect(stream.Next()).Should(BeTrue())
+			Expect(stream.Decode(&item)).Should(BeNil())
+
+			Expect(stream.Next()).Should(BeTrue())
+			err = stream.Decode(&item)
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.(*JSONStreamError).Index).Should(Equal(1))
+
+			Expect(stream.Next()).Should(BeFalse())
+			Expect(stream.Err().(*JSONStreamError).Index).Should(Equal(2))
+		})
+	})
+}